@@ -0,0 +1,340 @@
+// Package bot runs chat bots on top of the mattermost client: it listens to
+// the websocket event stream, routes posts to handlers and replies in thread.
+package bot
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// HandlerFunc handles a post routed to the bot.
+type HandlerFunc func(c *Context) error
+
+// Middleware wraps handlers, for example to check who may run a command.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type routeKind int
+
+const (
+	routeHear routeKind = iota
+	routeMention
+	routeDirect
+	routeThreadReply
+)
+
+type route struct {
+	kind    routeKind
+	pattern *regexp.Regexp
+	handler HandlerFunc
+}
+
+// Bot dispatches the posts it receives to the first matching handler, in the
+// order handlers were registered. Posts made by the bot itself and system
+// messages are ignored.
+type Bot struct {
+	Client *mattermost.Client4
+
+	// Typing tracks which users are typing where.
+	Typing *mattermost.TypingTracker
+
+	// ErrorHandler is called with errors returned by handlers. When nil,
+	// errors are dropped.
+	ErrorHandler func(c *Context, err error)
+
+	mu            sync.RWMutex
+	routes        []route
+	middlewares   []Middleware
+	self          *mattermost.User
+	mention       *regexp.Regexp // Matches an @mention of self in a message
+	ws            *mattermost.WebSocketClient
+	threads       map[string]time.Time // Threads the bot has posted in, with the time of its last post
+	threadsPruned time.Time
+	onTyping      []func(c context.Context, ev *mattermost.TypingEvent)
+	state         *stateStore
+	wg            sync.WaitGroup
+}
+
+// New returns a bot acting as the user client is authenticated as.
+func New(client *mattermost.Client4) *Bot {
+	return &Bot{
+		Client:        client,
+		Typing:        mattermost.NewTypingTracker(),
+		threads:       map[string]time.Time{},
+		threadsPruned: time.Now(),
+		state:         newStateStore(),
+	}
+}
+
+// Use adds middlewares applied to every handler. The first one registered is
+// the outermost.
+func (b *Bot) Use(middlewares ...Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// Hear handles every post whose message matches pattern, in any channel the
+// bot is a member of. An empty pattern matches every post.
+func (b *Bot) Hear(pattern string, h HandlerFunc) {
+	b.addRoute(routeHear, pattern, h)
+}
+
+// OnMention handles posts that @mention the bot. pattern is matched against
+// the message with the mention removed.
+func (b *Bot) OnMention(pattern string, h HandlerFunc) {
+	b.addRoute(routeMention, pattern, h)
+}
+
+// OnDirect handles posts sent to the bot in a direct message channel.
+func (b *Bot) OnDirect(pattern string, h HandlerFunc) {
+	b.addRoute(routeDirect, pattern, h)
+}
+
+// OnThreadReply handles replies in threads the bot has posted in, whether
+// through Context.Reply or with its client directly. Threads the bot has not
+// posted in for a day are forgotten.
+func (b *Bot) OnThreadReply(pattern string, h HandlerFunc) {
+	b.addRoute(routeThreadReply, pattern, h)
+}
+
+func (b *Bot) addRoute(kind routeKind, pattern string, h HandlerFunc) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		re = regexp.MustCompile(pattern)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.routes = append(b.routes, route{kind: kind, pattern: re, handler: h})
+}
+
+// OnTyping registers a function called when another user starts typing in a
+// channel the bot is a member of.
+func (b *Bot) OnTyping(f func(ctx context.Context, ev *mattermost.TypingEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onTyping = append(b.onTyping, f)
+}
+
+// Self returns the user the bot runs as, once Run has started.
+func (b *Bot) Self() *mattermost.User {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.self
+}
+
+// WebSocket returns the websocket connection of the bot, once Run has started.
+func (b *Bot) WebSocket() *mattermost.WebSocketClient {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.ws
+}
+
+// Run connects to the server and handles posts until ctx is done. Handlers
+// still running when ctx is done are waited for.
+func (b *Bot) Run(ctx context.Context) error {
+	self, _, err := b.Client.GetMeContext(ctx, "")
+	if err != nil {
+		return err
+	}
+	ws := mattermost.NewWebSocketClient(b.Client)
+	// Usernames may contain dots and dashes, so "@bot" in "@bot-dev" is not
+	// a mention; a dot ending the sentence is not part of the name.
+	mention := regexp.MustCompile(`@` + regexp.QuoteMeta(self.Username) + `\.?([^A-Za-z0-9._\-]|$)`)
+
+	b.mu.Lock()
+	b.self = self
+	b.mention = mention
+	b.ws = ws
+	b.mu.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ws.Run(ctx)
+	}()
+
+	for ev := range ws.EventChannel {
+		if ev.Event != mattermost.WebsocketEventPosted && ev.Event != mattermost.WebsocketEventTyping {
+			continue
+		}
+		decoded, err := mattermost.DecodeEvent(ev)
+		if err != nil {
+			b.handleError(&Context{Context: ctx, Bot: b}, err)
+			continue
+		}
+		b.Typing.Observe(decoded)
+		switch e := decoded.(type) {
+		case *mattermost.PostedEvent:
+			b.dispatch(ctx, e)
+		case *mattermost.TypingEvent:
+			b.notifyTyping(ctx, e)
+		}
+	}
+	b.wg.Wait()
+
+	err = <-errCh
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func (b *Bot) dispatch(ctx context.Context, ev *mattermost.PostedEvent) {
+	post := ev.Post
+	self := b.Self()
+	if post.UserId == self.Id {
+		// Replies to the posts the bot made with its client go to
+		// OnThreadReply as well as the replies to Context.Reply.
+		rootId := post.RootId
+		if rootId == "" {
+			rootId = post.Id
+		}
+		b.trackThread(rootId)
+		return
+	}
+	if strings.HasPrefix(post.Type, mattermost.PostSystemMessagePrefix) {
+		return
+	}
+
+	b.mu.RLock()
+	routes := b.routes
+	middlewares := b.middlewares
+	_, inThread := b.threads[post.RootId]
+	mention := b.mention
+	b.mu.RUnlock()
+
+	mentioned, text := stripMention(ev, self, mention)
+	for _, r := range routes {
+		message := post.Message
+		switch r.kind {
+		case routeMention:
+			if !mentioned {
+				continue
+			}
+			message = text
+		case routeDirect:
+			if ev.ChannelType != mattermost.ChannelTypeDirect {
+				continue
+			}
+		case routeThreadReply:
+			if !inThread {
+				continue
+			}
+		}
+
+		var matches []string
+		if r.pattern != nil {
+			if matches = r.pattern.FindStringSubmatch(message); matches == nil {
+				continue
+			}
+		}
+
+		c := &Context{
+			Context: ctx,
+			Bot:     b,
+			Post:    post,
+			Event:   ev,
+			Message: message,
+			Matches: matches,
+		}
+		h := r.handler
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			if err := h(c); err != nil {
+				b.handleError(c, err)
+			}
+		}()
+		return
+	}
+}
+
+// stripMention reports whether the post mentions self and returns the message
+// without the mention. The users the server found mentioned are authoritative;
+// the message is only searched when the event does not list them.
+func stripMention(ev *mattermost.PostedEvent, self *mattermost.User, mention *regexp.Regexp) (bool, string) {
+	mentioned := slices.Contains(ev.Mentions, self.Id)
+	if ev.Mentions == nil {
+		mentioned = mention.MatchString(ev.Post.Message)
+	}
+	if !mentioned {
+		return false, ev.Post.Message
+	}
+	text := strings.TrimSpace(mention.ReplaceAllString(ev.Post.Message, "$1"))
+	return true, text
+}
+
+func (b *Bot) notifyTyping(ctx context.Context, ev *mattermost.TypingEvent) {
+	if ev.UserId == b.Self().Id {
+		return
+	}
+	b.mu.RLock()
+	callbacks := b.onTyping
+	b.mu.RUnlock()
+	for _, f := range callbacks {
+		f(ctx, ev)
+	}
+}
+
+// trackThread records that the bot posted in the thread of rootId and forgets
+// the threads it has been quiet in for conversationTTL.
+func (b *Bot) trackThread(rootId string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.threadsPruned) > time.Hour {
+		for id, last := range b.threads {
+			if now.Sub(last) > conversationTTL {
+				delete(b.threads, id)
+			}
+		}
+		b.threadsPruned = now
+	}
+	b.threads[rootId] = now
+}
+
+func (b *Bot) handleError(c *Context, err error) {
+	if b.ErrorHandler != nil {
+		b.ErrorHandler(c, err)
+	}
+}
+
+// AllowUsers returns a middleware that only runs handlers for posts made by
+// the given users and ignores everyone else.
+func AllowUsers(userIds ...string) Middleware {
+	allowed := map[string]bool{}
+	for _, id := range userIds {
+		allowed[id] = true
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if !allowed[c.Post.UserId] {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// KeepTyping returns a middleware that shows the bot as typing in the thread of
+// the post while the handler runs.
+func KeepTyping() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			stop := c.KeepTyping()
+			defer stop()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package bot_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/bot"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+// reply waits for the bot to post in the thread of rootId and returns the
+// message, or "" when it does not answer.
+func reply(s *mattermosttest.Server, botId, channelId, rootId string, wait time.Duration) string {
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		for _, p := range s.Posts(channelId) {
+			if p.UserId == botId && p.RootId == rootId {
+				return p.Message
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return ""
+}
+
+// startBot runs b until the test ends and waits for it to connect.
+func startBot(t *testing.T, b *bot.Bot) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	for i := 0; i < 200; i++ {
+		if ws := b.WebSocket(); ws != nil && ws.ConnectionId() != "" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("bot did not connect")
+}
+
+func TestBotRoutes(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	helper := s.AddUser(&mattermost.User{Username: "helper"}, "secret")
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	helperDev := s.AddUser(&mattermost.User{Username: "helper-dev"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	for _, u := range []*mattermost.User{helper, alice, helperDev} {
+		s.AddChannelMember(ch.Id, u.Id)
+	}
+	ac := s.Client(alice.Id)
+	dm, _, err := ac.CreateDirectChannel(alice.Id, helper.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := bot.New(s.Client(helper.Id))
+	b.Hear(`^ping$`, func(c *bot.Context) error {
+		_, err := c.Reply("pong")
+		return err
+	})
+	b.OnMention(`^echo (.+)$`, func(c *bot.Context) error {
+		_, err := c.Reply(c.Matches[1])
+		return err
+	})
+	b.OnDirect("", func(c *bot.Context) error {
+		_, err := c.Reply(fmt.Sprintf("direct: %s", c.Message))
+		return err
+	})
+	b.OnThreadReply("", func(c *bot.Context) error {
+		n, _ := c.State().Get("replies")
+		count, _ := n.(int)
+		c.State().Set("replies", count+1)
+		_, err := c.Reply(fmt.Sprintf("reply %d", count+1))
+		return err
+	})
+	startBot(t, b)
+
+	var pingId string
+	for _, tc := range []struct {
+		name      string
+		channelId string
+		message   string
+		inPing    bool // Post in the thread of the ping post
+		want      string
+	}{
+		{name: "hear", channelId: ch.Id, message: "ping", want: "pong"},
+		{name: "mention", channelId: ch.Id, message: "@helper echo hello there", want: "hello there"},
+		{name: "direct", channelId: dm.Id, message: "hi", want: "direct: hi"},
+		{name: "reply in the bot's thread", channelId: ch.Id, message: "thanks", inPing: true, want: "reply 1"},
+		{name: "state is kept per thread", channelId: ch.Id, message: "again", inPing: true, want: "reply 2"},
+		{name: "no route", channelId: ch.Id, message: "hello"},
+		{name: "mention of another user", channelId: ch.Id, message: "echo hi @helper-dev"},
+		// No user has these names, so the server lists no mentions and the
+		// bot looks for its name in the message.
+		{name: "unknown user with the bot's name as prefix", channelId: ch.Id, message: "echo hi @helper.staging"},
+		{name: "mention ending a sentence", channelId: ch.Id, message: "@helper. echo hi", want: "hi"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rootId := ""
+			if tc.inPing {
+				rootId = pingId
+			}
+			before := len(s.Posts(tc.channelId))
+			p, _, err := ac.CreateSimpleMessagePost(tc.channelId, tc.message, rootId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pingId == "" {
+				pingId = p.Id
+			}
+			if rootId == "" {
+				rootId = p.Id
+			}
+
+			wait := 2 * time.Second
+			if tc.want == "" {
+				wait = 100 * time.Millisecond
+			}
+			if !tc.inPing {
+				if got := reply(s, helper.Id, tc.channelId, rootId, wait); got != tc.want {
+					t.Fatalf("reply %q, want %q", got, tc.want)
+				}
+				return
+			}
+			// Replies in the ping thread: look at the newest bot post.
+			deadline := time.Now().Add(wait)
+			for time.Now().Before(deadline) {
+				posts := s.Posts(tc.channelId)
+				if last := posts[len(posts)-1]; len(posts) > before+1 && last.UserId == helper.Id {
+					if last.Message != tc.want || last.RootId != pingId {
+						t.Fatalf("reply %+v, want %q", last, tc.want)
+					}
+					return
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			t.Fatalf("no reply, want %q", tc.want)
+		})
+	}
+
+	t.Run("reply to a post made with the client", func(t *testing.T) {
+		root, _, err := b.Client.CreateSimpleMessagePost(ch.Id, "disk full", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ac.CreateSimpleMessagePost(ch.Id, "on it", root.Id); err != nil {
+			t.Fatal(err)
+		}
+		if got := reply(s, helper.Id, ch.Id, root.Id, 2*time.Second); got != "reply 1" {
+			t.Fatalf("reply %q, want %q", got, "reply 1")
+		}
+	})
+}
+
+func TestBotMiddleware(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	helper := s.AddUser(&mattermost.User{Username: "helper"}, "secret")
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	eve := s.AddUser(&mattermost.User{Username: "eve"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "ops", Type: mattermost.ChannelTypeOpen})
+	for _, u := range []*mattermost.User{helper, alice, eve} {
+		s.AddChannelMember(ch.Id, u.Id)
+	}
+
+	errs := make(chan error, 1)
+	b := bot.New(s.Client(helper.Id))
+	b.ErrorHandler = func(_ *bot.Context, err error) { errs <- err }
+	b.Use(bot.AllowUsers(alice.Id))
+	b.Hear(`^deploy$`, func(c *bot.Context) error {
+		_, err := c.Reply("deploying")
+		return err
+	})
+	b.Hear(`^fail$`, func(c *bot.Context) error {
+		return fmt.Errorf("failed for %s", c.Post.UserId)
+	})
+	startBot(t, b)
+
+	for _, tc := range []struct {
+		name   string
+		userId string
+		want   string
+	}{
+		{name: "allowed", userId: alice.Id, want: "deploying"},
+		{name: "not allowed", userId: eve.Id},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _, err := s.Client(tc.userId).CreateSimpleMessagePost(ch.Id, "deploy", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wait := 2 * time.Second
+			if tc.want == "" {
+				wait = 100 * time.Millisecond
+			}
+			if got := reply(s, helper.Id, ch.Id, p.Id, wait); got != tc.want {
+				t.Fatalf("reply %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("handler error", func(t *testing.T) {
+		if _, _, err := s.Client(alice.Id).CreateSimpleMessagePost(ch.Id, "fail", ""); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-errs:
+			if err.Error() != "failed for "+alice.Id {
+				t.Fatalf("err = %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("error handler not called")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"context"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// Context carries the post being handled.
+type Context struct {
+	context.Context
+	Bot     *Bot
+	Post    *mattermost.Post
+	Event   *mattermost.PostedEvent
+	Message string   // The message matched by the route, without the bot mention for OnMention
+	Matches []string // Submatches of the route pattern, nil when the route has no pattern
+}
+
+// ThreadId returns the id of the thread the post belongs to. A post outside of
+// any thread starts one.
+func (c *Context) ThreadId() string {
+	if c.Post.RootId != "" {
+		return c.Post.RootId
+	}
+	return c.Post.Id
+}
+
+// IsDirect reports whether the post was sent in a direct message channel.
+func (c *Context) IsDirect() bool {
+	return c.Event.ChannelType == mattermost.ChannelTypeDirect
+}
+
+// Reply posts message in the thread of the handled post.
+func (c *Context) Reply(message string) (*mattermost.Post, error) {
+	rootId := c.ThreadId()
+	post, _, err := c.Bot.Client.CreateSimpleMessagePostContext(c, c.Post.ChannelId, message, rootId)
+	if err != nil {
+		return nil, err
+	}
+	c.Bot.trackThread(rootId)
+	return post, nil
+}
+
+// KeepTyping shows the bot as typing in the thread of the post until the
+// returned function is called. Replies are posted in the thread, so that is
+// where the indicator is shown.
+func (c *Context) KeepTyping() (stop func()) {
+	return c.Bot.WebSocket().KeepTyping(c, c.Post.ChannelId, c.ThreadId())
+}
+
+// State returns the state of the conversation the post belongs to: the thread
+// in the post's channel.
+func (c *Context) State() *Conversation {
+	return c.Bot.state.get(c.Post.ChannelId + "/" + c.ThreadId())
+}
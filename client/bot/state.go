@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// conversationTTL is how long the state of an idle conversation is kept.
+const conversationTTL = 24 * time.Hour
+
+// Conversation holds values shared by the handlers of a conversation. It is
+// safe for concurrent use.
+type Conversation struct {
+	mu       sync.Mutex
+	values   map[string]any
+	lastUsed time.Time
+}
+
+func (c *Conversation) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	return v, ok
+}
+
+func (c *Conversation) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+func (c *Conversation) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+}
+
+type stateStore struct {
+	mu            sync.Mutex
+	conversations map[string]*Conversation
+	lastPrune     time.Time
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{conversations: map[string]*Conversation{}, lastPrune: time.Now()}
+}
+
+func (s *stateStore) get(key string) *Conversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > time.Hour {
+		for k, c := range s.conversations {
+			if now.Sub(c.lastUsed) > conversationTTL {
+				delete(s.conversations, k)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	c, ok := s.conversations[key]
+	if !ok {
+		c = &Conversation{values: map[string]any{}}
+		s.conversations[key] = c
+	}
+	c.lastUsed = now
+	return c
+}
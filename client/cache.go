@@ -0,0 +1,206 @@
+package mattermost
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheMaxEntries = 1000
+	DefaultCacheMaxBytes   = 32 << 20
+	DefaultCacheTTL        = 10 * time.Minute
+)
+
+// ResponseCache stores GET response bodies by URL and credentials so that
+// Client4 can revalidate them with If-None-Match and serve the cached body
+// when the server answers 304 Not Modified. Entries are evicted least recently
+// used first once MaxEntries or MaxBytes is exceeded, and expire after TTL.
+// It is safe for concurrent use.
+type ResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	size       int64
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key      string
+	url      string
+	etag     string
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+// NewResponseCache returns an empty cache. A zero or negative limit disables
+// that limit; a zero ttl keeps entries until they are evicted.
+func NewResponseCache(maxEntries int, maxBytes int64, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+// Len returns the number of cached responses.
+func (rc *ResponseCache) Len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.ll.Len()
+}
+
+// Purge removes every cached response.
+func (rc *ResponseCache) Purge() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.ll.Init()
+	rc.items = map[string]*list.Element{}
+	rc.size = 0
+}
+
+func cacheKey(url, auth string) string {
+	sum := sha256.Sum256([]byte(auth))
+	return url + "\x00" + hex.EncodeToString(sum[:])
+}
+
+func (rc *ResponseCache) get(key string) *cacheEntry {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	el, ok := rc.items[key]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if rc.ttl > 0 && time.Since(entry.storedAt) > rc.ttl {
+		rc.remove(el)
+		return nil
+	}
+	rc.ll.MoveToFront(el)
+	return entry
+}
+
+func (rc *ResponseCache) put(entry *cacheEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[entry.key]; ok {
+		rc.remove(el)
+	}
+	if rc.maxBytes > 0 && int64(len(entry.body)) > rc.maxBytes {
+		return
+	}
+	rc.items[entry.key] = rc.ll.PushFront(entry)
+	rc.size += int64(len(entry.body))
+
+	for (rc.maxEntries > 0 && rc.ll.Len() > rc.maxEntries) || (rc.maxBytes > 0 && rc.size > rc.maxBytes) {
+		rc.remove(rc.ll.Back())
+	}
+}
+
+// invalidate drops every entry for url, whatever credentials it was stored with.
+func (rc *ResponseCache) invalidate(url string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for el := rc.ll.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*cacheEntry).url == url {
+			rc.remove(el)
+		}
+		el = next
+	}
+}
+
+func (rc *ResponseCache) remove(el *list.Element) {
+	entry := rc.ll.Remove(el).(*cacheEntry)
+	delete(rc.items, entry.key)
+	rc.size -= int64(len(entry.body))
+}
+
+// revalidate adds If-None-Match to a GET request whose response is cached. It
+// returns the key under which the response should be stored, or "" when the
+// request is not cacheable, for example because the caller manages the ETag
+// or asks for part of the body.
+func (rc *ResponseCache) revalidate(rq *http.Request) (string, *cacheEntry) {
+	if rq.Method != http.MethodGet || rq.Header.Get(HeaderEtagClient) != "" || rq.Header.Get(HeaderRange) != "" {
+		return "", nil
+	}
+	key := cacheKey(rq.URL.String(), rq.Header.Get(HeaderAuth))
+	entry := rc.get(key)
+	if entry != nil {
+		rq.Header.Set(HeaderEtagClient, entry.etag)
+	}
+	return key, entry
+}
+
+// store updates the cache from rp. A 304 answering a revalidated request is
+// turned into a 200 carrying the cached body, and a fresh 200 with an ETag is
+// remembered while its body is handed back to the caller unchanged.
+func (rc *ResponseCache) store(key string, entry *cacheEntry, rq *http.Request, rp *http.Response) {
+	if rq.Method != http.MethodGet {
+		if rp.StatusCode < 300 {
+			rc.invalidate(rq.URL.String())
+		}
+		return
+	}
+	if key == "" {
+		return
+	}
+
+	if rp.StatusCode == http.StatusNotModified && entry != nil {
+		closeBody(rp)
+		rp.StatusCode = http.StatusOK
+		rp.Status = strconv.Itoa(http.StatusOK) + " " + http.StatusText(http.StatusOK)
+		for k, v := range entry.header {
+			if rp.Header.Get(k) == "" {
+				rp.Header[k] = v
+			}
+		}
+		rp.ContentLength = int64(len(entry.body))
+		rp.Body = io.NopCloser(bytes.NewReader(entry.body))
+		return
+	}
+
+	etag := rp.Header.Get(HeaderEtagServer)
+	if rp.StatusCode != http.StatusOK || etag == "" {
+		return
+	}
+	if rc.maxBytes > 0 && rp.ContentLength > rc.maxBytes {
+		return
+	}
+
+	limit := rc.maxBytes
+	if limit <= 0 {
+		limit = DefaultCacheMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(rp.Body, limit+1))
+	rest := rp.Body
+	rp.Body = readCloser{io.MultiReader(bytes.NewReader(body), rest), rest}
+	if err != nil || int64(len(body)) > limit {
+		return
+	}
+
+	rc.put(&cacheEntry{
+		key:      key,
+		url:      rq.URL.String(),
+		etag:     etag,
+		header:   rp.Header.Clone(),
+		body:     body,
+		storedAt: time.Now(),
+	})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
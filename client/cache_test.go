@@ -0,0 +1,161 @@
+package mattermost_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+// statusRecorder records the status codes returned by the server, before the
+// cache turns a 304 into the cached response.
+type statusRecorder struct {
+	mu       sync.Mutex
+	statuses []int
+}
+
+func (sr *statusRecorder) middleware(next http.RoundTripper) http.RoundTripper {
+	return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+		rp, err := next.RoundTrip(rq)
+		if rp != nil {
+			sr.mu.Lock()
+			sr.statuses = append(sr.statuses, rp.StatusCode)
+			sr.mu.Unlock()
+		}
+		return rp, err
+	})
+}
+
+func (sr *statusRecorder) last() int {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if len(sr.statuses) == 0 {
+		return 0
+	}
+	return sr.statuses[len(sr.statuses)-1]
+}
+
+func TestResponseCache(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "cached", Email: "cached@example.com"}, "password")
+	other := s.AddUser(&mattermost.User{Username: "other"}, "password")
+
+	newClient := func(userId string, cache *mattermost.ResponseCache) (*mattermost.Client4, *statusRecorder) {
+		c := s.Client(userId)
+		c.Cache = cache
+		sr := &statusRecorder{}
+		c.Use(sr.middleware)
+		return c, sr
+	}
+
+	t.Run("serves the cached body on 304", func(t *testing.T) {
+		c, sr := newClient(u.Id, mattermost.NewResponseCache(10, 1<<20, time.Minute))
+		for i, wantStatus := range []int{http.StatusOK, http.StatusNotModified, http.StatusNotModified} {
+			me, rp, err := c.GetMe("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if me.Username != "cached" || rp.StatusCode != http.StatusOK {
+				t.Fatalf("call %d: user %+v, status %d", i+1, me, rp.StatusCode)
+			}
+			if got := sr.last(); got != wantStatus {
+				t.Fatalf("call %d: server status %d, want %d", i+1, got, wantStatus)
+			}
+		}
+	})
+
+	t.Run("caller managed ETag is left alone", func(t *testing.T) {
+		c, _ := newClient(u.Id, mattermost.NewResponseCache(10, 1<<20, time.Minute))
+		_, rp, err := c.GetMe("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, rp, err = c.GetMe(rp.Etag)
+		if err != nil || rp.StatusCode != http.StatusNotModified {
+			t.Fatalf("status %v, err %v", rp, err)
+		}
+	})
+
+	t.Run("entries are per credentials", func(t *testing.T) {
+		cache := mattermost.NewResponseCache(10, 1<<20, time.Minute)
+		c1, _ := newClient(u.Id, cache)
+		c2, sr2 := newClient(other.Id, cache)
+		if _, _, err := c1.GetMe(""); err != nil {
+			t.Fatal(err)
+		}
+		me, _, err := c2.GetMe("")
+		if err != nil || me.Id != other.Id || sr2.last() != http.StatusOK {
+			t.Fatalf("user %+v, server status %d, err %v", me, sr2.last(), err)
+		}
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		c, sr := newClient(u.Id, mattermost.NewResponseCache(10, 1<<20, 20*time.Millisecond))
+		if _, _, err := c.GetMe(""); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(40 * time.Millisecond)
+		if _, _, err := c.GetMe(""); err != nil || sr.last() != http.StatusOK {
+			t.Fatalf("server status %d, err %v", sr.last(), err)
+		}
+		if c.Cache.Len() != 1 {
+			t.Fatalf("len = %d", c.Cache.Len())
+		}
+	})
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		c, sr := newClient(u.Id, mattermost.NewResponseCache(1, 1<<20, time.Minute))
+		if _, _, err := c.GetMe(""); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.GetUser(other.Id, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.GetMe(""); err != nil || sr.last() != http.StatusOK || c.Cache.Len() != 1 {
+			t.Fatalf("server status %d, len %d, err %v", sr.last(), c.Cache.Len(), err)
+		}
+	})
+
+	t.Run("bodies above the size limit are not kept", func(t *testing.T) {
+		c, _ := newClient(u.Id, mattermost.NewResponseCache(10, 10, time.Minute))
+		if _, _, err := c.GetMe(""); err != nil || c.Cache.Len() != 0 {
+			t.Fatalf("len %d, err %v", c.Cache.Len(), err)
+		}
+	})
+
+	t.Run("writes invalidate the URL", func(t *testing.T) {
+		ch := s.AddChannel(&mattermost.Channel{Name: "cache"})
+		s.AddChannelMember(ch.Id, u.Id)
+		c, sr := newClient(u.Id, mattermost.NewResponseCache(10, 1<<20, time.Minute))
+		post, _, err := c.CreateSimpleMessagePost(ch.Id, "before", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.GetPost(post.Id, ""); err != nil {
+			t.Fatal(err)
+		}
+		post.Message = "after"
+		if _, _, err := c.UpdatePost(post.Id, post); err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := c.GetPost(post.Id, "")
+		if err != nil || got.Message != "after" || sr.last() != http.StatusOK {
+			t.Fatalf("message %q, server status %d, err %v", got.Message, sr.last(), err)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		c, _ := newClient(u.Id, mattermost.NewResponseCache(10, 1<<20, time.Minute))
+		if _, _, err := c.GetMe(""); err != nil {
+			t.Fatal(err)
+		}
+		c.Cache.Purge()
+		if c.Cache.Len() != 0 {
+			t.Fatalf("len = %d", c.Cache.Len())
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package mattermost
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -73,8 +74,12 @@ type ChannelMembers []ChannelMember
 // CreateDirectChannel creates a direct message channel based on the two user
 // ids provided.
 func (c *Client4) CreateDirectChannel(userId1, userId2 string) (*Channel, *Response, error) {
+	return c.CreateDirectChannelContext(context.Background(), userId1, userId2)
+}
+
+func (c *Client4) CreateDirectChannelContext(ctx context.Context, userId1, userId2 string) (*Channel, *Response, error) {
 	requestBody := []string{userId1, userId2}
-	r, err := c.DoAPIPost(c.channelsRoute()+"/direct", ArrayToJSON(requestBody))
+	r, err := c.DoAPIPostContext(ctx, c.channelsRoute()+"/direct", ArrayToJSON(requestBody))
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -83,14 +88,18 @@ func (c *Client4) CreateDirectChannel(userId1, userId2 string) (*Channel, *Respo
 	var ch *Channel
 	err = json.NewDecoder(r.Body).Decode(&ch)
 	if err != nil {
-		return nil, BuildResponse(r), NewAppError("CreateDirectChannel", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		return nil, BuildResponse(r), NewAppError("CreateDirectChannel", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 	return ch, BuildResponse(r), nil
 }
 
 // GetChannelMember gets a channel member.
 func (c *Client4) GetChannelMember(channelId, userId, etag string) (*ChannelMember, *Response, error) {
-	r, err := c.DoAPIGet(c.channelMemberRoute(channelId, userId), etag)
+	return c.GetChannelMemberContext(context.Background(), channelId, userId, etag)
+}
+
+func (c *Client4) GetChannelMemberContext(ctx context.Context, channelId, userId, etag string) (*ChannelMember, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.channelMemberRoute(channelId, userId), etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -99,15 +108,19 @@ func (c *Client4) GetChannelMember(channelId, userId, etag string) (*ChannelMemb
 	var ch *ChannelMember
 	err = json.NewDecoder(r.Body).Decode(&ch)
 	if err != nil {
-		return nil, BuildResponse(r), NewAppError("GetChannelMember", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		return nil, BuildResponse(r), NewAppError("GetChannelMember", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 	return ch, BuildResponse(r), nil
 }
 
 // GetChannelMembers gets a page of channel members specific to a channel.
 func (c *Client4) GetChannelMembers(channelId string, page, perPage int, etag string) (ChannelMembers, *Response, error) {
+	return c.GetChannelMembersContext(context.Background(), channelId, page, perPage, etag)
+}
+
+func (c *Client4) GetChannelMembersContext(ctx context.Context, channelId string, page, perPage int, etag string) (ChannelMembers, *Response, error) {
 	query := fmt.Sprintf("?page=%v&per_page=%v", page, perPage)
-	r, err := c.DoAPIGet(c.channelMembersRoute(channelId)+query, etag)
+	r, err := c.DoAPIGetContext(ctx, c.channelMembersRoute(channelId)+query, etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -116,23 +129,27 @@ func (c *Client4) GetChannelMembers(channelId string, page, perPage int, etag st
 	var ch ChannelMembers
 	err = json.NewDecoder(r.Body).Decode(&ch)
 	if err != nil {
-		return nil, BuildResponse(r), NewAppError("GetChannelMembers", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		return nil, BuildResponse(r), NewAppError("GetChannelMembers", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 	return ch, BuildResponse(r), nil
 }
 
 func PrepareChannelId(c *Client4, mattermostChannel string) (string, error) {
+	return PrepareChannelIdContext(context.Background(), c, mattermostChannel)
+}
+
+func PrepareChannelIdContext(ctx context.Context, c *Client4, mattermostChannel string) (string, error) {
 
 	if strings.HasPrefix(mattermostChannel, "@") {
-		userFrom, _, err := c.GetMe("")
+		userFrom, _, err := c.GetMeContext(ctx, "")
 		if err != nil {
 			return "", err
 		}
-		userTo, _, err := c.GetUserByUsername(strings.TrimLeft(mattermostChannel, "@"), "")
+		userTo, _, err := c.GetUserByUsernameContext(ctx, strings.TrimLeft(mattermostChannel, "@"), "")
 		if err != nil {
 			return "", err
 		}
-		channel, _, err := c.CreateDirectChannel(userFrom.Id, userTo.Id)
+		channel, _, err := c.CreateDirectChannelContext(ctx, userFrom.Id, userTo.Id)
 		if err != nil {
 			return "", err
 		}
@@ -144,7 +161,11 @@ func PrepareChannelId(c *Client4, mattermostChannel string) (string, error) {
 
 // GetChannel returns a channel based on the provided channel id string.
 func (c *Client4) GetChannel(channelId, etag string) (*Channel, *Response, error) {
-	r, err := c.DoAPIGet(c.channelRoute(channelId), etag)
+	return c.GetChannelContext(context.Background(), channelId, etag)
+}
+
+func (c *Client4) GetChannelContext(ctx context.Context, channelId, etag string) (*Channel, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.channelRoute(channelId), etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -153,7 +174,7 @@ func (c *Client4) GetChannel(channelId, etag string) (*Channel, *Response, error
 	var ch *Channel
 	err = json.NewDecoder(r.Body).Decode(&ch)
 	if err != nil {
-		return nil, BuildResponse(r), NewAppError("GetChannel", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		return nil, BuildResponse(r), NewAppError("GetChannel", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 	return ch, BuildResponse(r), nil
 }
@@ -0,0 +1,142 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ChannelPostsOptions selects the posts returned by GetPostsForChannel. Before
+// and After are post ids used as cursors; Since is a timestamp in
+// milliseconds returning every post changed after it, in which case paging is
+// ignored by the server.
+type ChannelPostsOptions struct {
+	Page             int
+	PerPage          int
+	Since            int64
+	Before           string
+	After            string
+	IncludeDeleted   bool
+	CollapsedThreads bool
+}
+
+func (o *ChannelPostsOptions) query(c *Client4) string {
+	v := url.Values{}
+	if o.Since > 0 {
+		v.Set("since", strconv.FormatInt(o.Since, 10))
+	} else {
+		v.Set("page", strconv.Itoa(o.Page))
+		perPage := o.PerPage
+		if perPage <= 0 {
+			perPage = DefaultPerPage
+		}
+		v.Set("per_page", strconv.Itoa(perPage))
+	}
+	if o.Before != "" {
+		v.Set("before", o.Before)
+	}
+	if o.After != "" {
+		v.Set("after", o.After)
+	}
+	if o.IncludeDeleted {
+		v.Set("include_deleted", c.boolString(true))
+	}
+	if o.CollapsedThreads {
+		v.Set("collapsedThreads", c.boolString(true))
+	}
+	return "?" + v.Encode()
+}
+
+// GetPostsForChannel gets a page of posts of a channel, newest first. A nil
+// opts returns the first page.
+func (c *Client4) GetPostsForChannel(channelId string, opts *ChannelPostsOptions, etag string) (*PostList, *Response, error) {
+	return c.GetPostsForChannelContext(context.Background(), channelId, opts, etag)
+}
+
+func (c *Client4) GetPostsForChannelContext(ctx context.Context, channelId string, opts *ChannelPostsOptions, etag string) (*PostList, *Response, error) {
+	if opts == nil {
+		opts = &ChannelPostsOptions{}
+	}
+	r, err := c.DoAPIGetContext(ctx, c.channelPostsRoute(channelId)+opts.query(c), etag)
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	list := NewPostList()
+	if r.StatusCode == http.StatusNotModified {
+		return list, BuildResponse(r), nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(list); err != nil {
+		return nil, nil, NewAppError("GetPostsForChannel", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if list.FirstInaccessiblePostTime == 0 {
+		list.FirstInaccessiblePostTime, _ = strconv.ParseInt(r.Header.Get(HeaderFirstInaccessiblePostTime), 10, 64)
+	}
+	return list, BuildResponse(r), nil
+}
+
+// ChannelExport summarizes an ExportChannel run.
+type ChannelExport struct {
+	Posts int // Number of posts written
+	// FirstInaccessiblePostTime is set when older posts exist but are hidden
+	// by the server, for example because of plan limits, so the export is
+	// incomplete.
+	FirstInaccessiblePostTime int64
+}
+
+// ChannelExportOptions selects the posts written by ExportChannel.
+type ChannelExportOptions struct {
+	// Since excludes the posts created before it, in milliseconds, which
+	// allows incremental archiving. Zero exports everything.
+	Since int64
+	// IncludeDeleted also exports deleted posts. The server only allows it to
+	// users with the permission to read deleted posts, system admins by default.
+	IncludeDeleted bool
+}
+
+// ExportChannel walks the history of a channel from the newest post to the
+// oldest one and writes each post to w as a line of JSON. Only one page is held
+// in memory at a time. A nil opts exports every post that is not deleted.
+func (c *Client4) ExportChannel(channelId string, opts *ChannelExportOptions, w io.Writer) (*ChannelExport, error) {
+	return c.ExportChannelContext(context.Background(), channelId, opts, w)
+}
+
+func (c *Client4) ExportChannelContext(ctx context.Context, channelId string, opts *ChannelExportOptions, w io.Writer) (*ChannelExport, error) {
+	if opts == nil {
+		opts = &ChannelExportOptions{}
+	}
+	enc := json.NewEncoder(w)
+	result := &ChannelExport{}
+	pageOpts := &ChannelPostsOptions{PerPage: DefaultPerPage, IncludeDeleted: opts.IncludeDeleted}
+
+	for {
+		list, _, err := c.GetPostsForChannelContext(ctx, channelId, pageOpts, "")
+		if err != nil {
+			return result, err
+		}
+		if list.FirstInaccessiblePostTime > result.FirstInaccessiblePostTime {
+			result.FirstInaccessiblePostTime = list.FirstInaccessiblePostTime
+		}
+		// The server may return fewer posts than asked for when it caps the
+		// page size, so only an empty page ends the history.
+		if len(list.Order) == 0 {
+			return result, nil
+		}
+
+		// Posts may also hold the root posts of replies on the page; only the
+		// ids in Order belong to the page.
+		for _, p := range list.ToSlice() {
+			if p.CreateAt < opts.Since {
+				return result, nil
+			}
+			if err := enc.Encode(p); err != nil {
+				return result, err
+			}
+			result.Posts++
+		}
+		pageOpts.Before = list.Order[len(list.Order)-1]
+	}
+}
@@ -0,0 +1,164 @@
+package mattermost_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+// channelWithPosts returns a server with a channel holding n posts by alice,
+// "post 0" being the oldest, and the created posts. An admin is also a member.
+func channelWithPosts(t *testing.T, n int) (s *mattermosttest.Server, alice, admin *mattermost.User, ch *mattermost.Channel, posts []*mattermost.Post) {
+	t.Helper()
+	s = mattermosttest.NewServer()
+	alice = s.AddUser(&mattermost.User{Username: "alice", Roles: "system_user"}, "secret")
+	admin = s.AddUser(&mattermost.User{Username: "admin", Roles: "system_user system_admin"}, "secret")
+	ch = s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	s.AddChannelMember(ch.Id, admin.Id)
+	c := s.Client(alice.Id)
+	for i := 0; i < n; i++ {
+		p, _, err := c.CreateSimpleMessagePost(ch.Id, fmt.Sprintf("post %d", i), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		posts = append(posts, p)
+	}
+	return s, alice, admin, ch, posts
+}
+
+func messages(list []*mattermost.Post) []string {
+	var res []string
+	for _, p := range list {
+		res = append(res, p.Message)
+	}
+	return res
+}
+
+func TestGetPostsForChannel(t *testing.T) {
+	s, alice, admin, ch, posts := channelWithPosts(t, 5)
+	defer s.Close()
+	c := s.Client(alice.Id)
+	if _, err := c.DeletePost(posts[2].Id); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		userId string
+		opts   *mattermost.ChannelPostsOptions
+		want   []string
+		status int
+	}{
+		{name: "first page", opts: nil, want: []string{"post 4", "post 3", "post 1", "post 0"}},
+		{name: "paging", opts: &mattermost.ChannelPostsOptions{Page: 1, PerPage: 2}, want: []string{"post 1", "post 0"}},
+		{name: "before", opts: &mattermost.ChannelPostsOptions{Before: posts[3].Id}, want: []string{"post 1", "post 0"}},
+		{name: "since", opts: &mattermost.ChannelPostsOptions{Since: posts[3].UpdateAt - 1}, want: []string{"post 4", "post 3"}},
+		{name: "deleted posts need permission", opts: &mattermost.ChannelPostsOptions{IncludeDeleted: true}, status: http.StatusForbidden},
+		{name: "deleted posts as admin", userId: admin.Id, opts: &mattermost.ChannelPostsOptions{IncludeDeleted: true}, want: []string{"post 4", "post 3", "post 2", "post 1", "post 0"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userId := tc.userId
+			if userId == "" {
+				userId = alice.Id
+			}
+			list, _, err := s.Client(userId).GetPostsForChannel(ch.Id, tc.opts, "")
+			if tc.status != 0 {
+				var appErr *mattermost.AppError
+				if !errors.As(err, &appErr) || appErr.StatusCode != tc.status {
+					t.Fatalf("err = %v, want status %d", err, tc.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messages(list.ToSlice()); !slices.Equal(got, tc.want) {
+				t.Fatalf("posts %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("first inaccessible post time from the header", func(t *testing.T) {
+		c := s.Client(alice.Id)
+		c.Use(func(next http.RoundTripper) http.RoundTripper {
+			return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+				rp, err := next.RoundTrip(rq)
+				if rp != nil {
+					rp.Header.Set(mattermost.HeaderFirstInaccessiblePostTime, "42")
+				}
+				return rp, err
+			})
+		})
+		list, _, err := c.GetPostsForChannel(ch.Id, nil, "")
+		if err != nil || list.FirstInaccessiblePostTime != 42 {
+			t.Fatalf("first inaccessible post time %d, err %v", list.FirstInaccessiblePostTime, err)
+		}
+	})
+}
+
+func TestExportChannel(t *testing.T) {
+	const n = 450 // More than two pages
+	s, alice, admin, ch, posts := channelWithPosts(t, n)
+	defer s.Close()
+	if _, err := s.Client(alice.Id).DeletePost(posts[420].Id); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		userId  string
+		opts    *mattermost.ChannelExportOptions
+		want    int
+		wantErr error
+	}{
+		{name: "everything", want: n - 1},
+		{name: "since", opts: &mattermost.ChannelExportOptions{Since: posts[400].CreateAt}, want: n - 400 - 1},
+		{name: "deleted posts need permission", opts: &mattermost.ChannelExportOptions{IncludeDeleted: true}, wantErr: mattermost.ErrForbidden},
+		{name: "deleted posts as admin", userId: admin.Id, opts: &mattermost.ChannelExportOptions{IncludeDeleted: true}, want: n},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userId := tc.userId
+			if userId == "" {
+				userId = alice.Id
+			}
+			var buf bytes.Buffer
+			res, err := s.Client(userId).ExportChannel(ch.Id, tc.opts, &buf)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines int
+			last := int64(1<<63 - 1)
+			scanner := bufio.NewScanner(&buf)
+			for scanner.Scan() {
+				var p mattermost.Post
+				if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+					t.Fatal(err)
+				}
+				if p.CreateAt > last {
+					t.Fatalf("post %q out of order", p.Message)
+				}
+				last = p.CreateAt
+				lines++
+			}
+			if res.Posts != tc.want || lines != tc.want {
+				t.Fatalf("%d posts, %d lines, want %d", res.Posts, lines, tc.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package mattermost
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 const (
@@ -27,6 +30,10 @@ const (
 	HeaderRequestedWithXML          = "XMLHttpRequest"
 	HeaderFirstInaccessiblePostTime = "First-Inaccessible-Post-Time"
 	HeaderRange                     = "Range"
+	HeaderRetryAfter                = "Retry-After"
+	HeaderRateLimitLimit            = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining        = "X-RateLimit-Remaining"
+	HeaderRateLimitReset            = "X-RateLimit-Reset"
 	STATUS                          = "status"
 	StatusOk                        = "OK"
 	StatusFail                      = "FAIL"
@@ -39,6 +46,10 @@ const (
 	APIURLSuffixV4 = "/api/v4"
 	APIURLSuffixV5 = "/api/v5"
 	APIURLSuffix   = APIURLSuffixV4
+
+	// LocalModeURL is the placeholder server location used by NewLocalClient;
+	// the host is ignored as requests are sent over the socket.
+	LocalModeURL = "http://_"
 )
 
 type Response struct {
@@ -53,10 +64,31 @@ type Client4 struct {
 	URL        string       // The location of the server, for example  "http://localhost:8065"
 	APIURL     string       // The api location of the server, for example "http://localhost:8065/api/v4"
 	HTTPClient *http.Client // The http client
-	AuthToken  string
+	AuthToken  string       // Use SetToken to change it while requests are in flight
 	AuthType   string
 	HTTPHeader map[string]string // Headers to be copied over for each request
 
+	// RetryPolicy enables retrying of failed requests. A nil policy disables retries.
+	RetryPolicy *RetryPolicy
+
+	// Cache enables transparent ETag revalidation of GET requests. A nil cache
+	// leaves ETag handling to the caller.
+	Cache *ResponseCache
+
+	// RateLimiter throttles requests on the client side. A nil limiter disables throttling.
+	RateLimiter *RateLimiter
+
+	mu          sync.RWMutex // Guards middlewares
+	middlewares []Middleware
+
+	authMu    sync.RWMutex      // Guards AuthToken, AuthType and loginData
+	loginMu   sync.Mutex        // Serializes automatic re-logins
+	loginData map[string]string // Credentials of the last password login, used to renew expired sessions
+
+	inflightMu sync.Mutex
+	inflight   map[string]int // Requests in flight per Authorization header
+	drained    chan struct{}  // Closed when a count in inflight drops to zero
+
 	// TrueString is the string value sent to the server for true boolean query parameters.
 	trueString string
 
@@ -64,9 +96,42 @@ type Client4 struct {
 	falseString string
 }
 
+// SetToken switches the client to token. Requests already in flight keep the
+// token they were sent with. Credentials remembered by Login are forgotten.
 func (c *Client4) SetToken(token string) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
 	c.AuthToken = token
 	c.AuthType = HeaderBearer
+	c.loginData = nil
+}
+
+func (c *Client4) boolString(value bool) string {
+	if value && c.trueString != "" {
+		return c.trueString
+	} else if value {
+		return "true"
+	}
+
+	if c.falseString != "" {
+		return c.falseString
+	}
+	return "false"
+}
+
+// authorization returns the value of the Authorization header for the current
+// session, or "" when the client is not authenticated.
+func (c *Client4) authorization() string {
+	c.authMu.RLock()
+	defer c.authMu.RUnlock()
+	return c.authorizationLocked()
+}
+
+func (c *Client4) authorizationLocked() string {
+	if c.AuthToken == "" {
+		return ""
+	}
+	return c.AuthType + " " + c.AuthToken
 }
 func BuildResponse(r *http.Response) *Response {
 	if r == nil {
@@ -83,52 +148,141 @@ func BuildResponse(r *http.Response) *Response {
 }
 func NewAPIv4Client(url string) *Client4 {
 	url = strings.TrimRight(url, "/")
-	return &Client4{url, url + APIURLSuffix, &http.Client{}, "", "", map[string]string{}, "", ""}
+	return &Client4{
+		URL:        url,
+		APIURL:     url + APIURLSuffix,
+		HTTPClient: &http.Client{},
+		HTTPHeader: map[string]string{},
+	}
+}
+
+// NewLocalClient returns a client for the local mode of a Mattermost server,
+// which serves the API without authentication on a Unix domain socket, for
+// example "/var/tmp/mattermost_local.socket". Local mode must be enabled with
+// ServiceSettings.EnableLocalMode.
+func NewLocalClient(socketPath string) *Client4 {
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	c := NewAPIv4Client(LocalModeURL)
+	c.HTTPClient = &http.Client{Transport: tr}
+	return c
 }
 
 func (c *Client4) DoAPIGet(url string, etag string) (*http.Response, error) {
-	return c.DoAPIRequest(http.MethodGet, c.APIURL+url, "", etag)
+	return c.DoAPIGetContext(context.Background(), url, etag)
+}
+
+func (c *Client4) DoAPIGetContext(ctx context.Context, url string, etag string) (*http.Response, error) {
+	return c.DoAPIRequestContext(ctx, http.MethodGet, c.APIURL+url, "", etag)
 }
 
 func (c *Client4) DoAPIPost(url string, data string) (*http.Response, error) {
-	return c.DoAPIRequest(http.MethodPost, c.APIURL+url, data, "")
+	return c.DoAPIPostContext(context.Background(), url, data)
+}
+
+func (c *Client4) DoAPIPostContext(ctx context.Context, url string, data string) (*http.Response, error) {
+	return c.DoAPIRequestContext(ctx, http.MethodPost, c.APIURL+url, data, "")
 }
 
 func (c *Client4) DoAPIPut(url string, data string) (*http.Response, error) {
-	return c.DoAPIRequest(http.MethodPut, c.APIURL+url, data, "")
+	return c.DoAPIPutContext(context.Background(), url, data)
+}
+
+func (c *Client4) DoAPIPutContext(ctx context.Context, url string, data string) (*http.Response, error) {
+	return c.DoAPIRequestContext(ctx, http.MethodPut, c.APIURL+url, data, "")
 }
 
 func (c *Client4) DoAPIPutBytes(url string, data []byte) (*http.Response, error) {
-	return c.DoAPIRequestBytes(http.MethodPut, c.APIURL+url, data, "")
+	return c.DoAPIPutBytesContext(context.Background(), url, data)
+}
+
+func (c *Client4) DoAPIPutBytesContext(ctx context.Context, url string, data []byte) (*http.Response, error) {
+	return c.DoAPIRequestBytesContext(ctx, http.MethodPut, c.APIURL+url, data, "")
 }
 
 func (c *Client4) DoAPIDelete(url string) (*http.Response, error) {
-	return c.DoAPIRequest(http.MethodDelete, c.APIURL+url, "", "")
+	return c.DoAPIDeleteContext(context.Background(), url)
 }
+
+func (c *Client4) DoAPIDeleteContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.DoAPIRequestContext(ctx, http.MethodDelete, c.APIURL+url, "", "")
+}
+
 func (c *Client4) DoAPIRequest(method, url, data, etag string) (*http.Response, error) {
-	return c.DoAPIRequestReader(method, url, strings.NewReader(data), map[string]string{HeaderEtagClient: etag})
+	return c.DoAPIRequestContext(context.Background(), method, url, data, etag)
+}
+
+func (c *Client4) DoAPIRequestContext(ctx context.Context, method, url, data, etag string) (*http.Response, error) {
+	return c.DoAPIRequestReaderContext(ctx, method, url, strings.NewReader(data), map[string]string{HeaderEtagClient: etag})
 }
 
 func (c *Client4) DoAPIRequestWithHeaders(method, url, data string, headers map[string]string) (*http.Response, error) {
-	return c.DoAPIRequestReader(method, url, strings.NewReader(data), headers)
+	return c.DoAPIRequestWithHeadersContext(context.Background(), method, url, data, headers)
+}
+
+func (c *Client4) DoAPIRequestWithHeadersContext(ctx context.Context, method, url, data string, headers map[string]string) (*http.Response, error) {
+	return c.DoAPIRequestReaderContext(ctx, method, url, strings.NewReader(data), headers)
 }
 
 func (c *Client4) DoAPIRequestBytes(method, url string, data []byte, etag string) (*http.Response, error) {
-	return c.DoAPIRequestReader(method, url, bytes.NewReader(data), map[string]string{HeaderEtagClient: etag})
+	return c.DoAPIRequestBytesContext(context.Background(), method, url, data, etag)
+}
+
+func (c *Client4) DoAPIRequestBytesContext(ctx context.Context, method, url string, data []byte, etag string) (*http.Response, error) {
+	return c.DoAPIRequestReaderContext(ctx, method, url, bytes.NewReader(data), map[string]string{HeaderEtagClient: etag})
 }
 
 func (c *Client4) DoAPIRequestReader(method, url string, data io.Reader, headers map[string]string) (*http.Response, error) {
-	rq, err := http.NewRequest(method, url, data)
+	return c.DoAPIRequestReaderContext(context.Background(), method, url, data, headers)
+}
+
+// DoAPIRequestReaderContext performs the request bound to ctx, so cancelling ctx
+// or reaching its deadline aborts the call. The request can only be retried or
+// replayed after a relogin when data implements io.ReaderAt and io.Seeker.
+func (c *Client4) DoAPIRequestReaderContext(ctx context.Context, method, url string, data io.Reader, headers map[string]string) (*http.Response, error) {
+	body, length, getBody := rewindableBody(data)
+	rq, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
+	if getBody != nil {
+		rq.ContentLength = length
+		rq.GetBody = getBody
+		if length == 0 {
+			rq.Body = http.NoBody
+		}
+	}
 
 	for k, v := range headers {
 		rq.Header.Set(k, v)
 	}
 
-	if c.AuthToken != "" {
-		rq.Header.Set(HeaderAuth, c.AuthType+" "+c.AuthToken)
+	auth, release := c.acquireAuthorization()
+	rp, err := c.do(rq, auth)
+	release()
+	if c.shouldRelogin(rq, err) {
+		if next, ok := rewindBody(rq); ok && c.relogin(ctx, auth) == nil {
+			if rp != nil {
+				closeBody(rp)
+			}
+			auth, release = c.acquireAuthorization()
+			rp, err = c.do(next, auth)
+			release()
+		}
+	}
+	return rp, err
+}
+
+// do sends a copy of rq authenticated with auth through the cache, the retry
+// policy and the middlewares, turning failed responses into an *AppError.
+func (c *Client4) do(rq *http.Request, auth string) (*http.Response, error) {
+	rq = rq.Clone(rq.Context())
+	if auth != "" {
+		rq.Header.Set(HeaderAuth, auth)
 	}
 
 	if c.HTTPHeader != nil && len(c.HTTPHeader) > 0 {
@@ -137,22 +291,51 @@ func (c *Client4) DoAPIRequestReader(method, url string, data io.Reader, headers
 		}
 	}
 
-	rp, err := c.HTTPClient.Do(rq)
+	var key string
+	var cached *cacheEntry
+	if c.Cache != nil {
+		key, cached = c.Cache.revalidate(rq)
+	}
+
+	rp, err := c.doRequest(rq)
 	if err != nil {
 		return rp, err
 	}
 
+	c.updateSessionToken(rp)
+
+	if c.Cache != nil {
+		c.Cache.store(key, cached, rq, rp)
+	}
+
 	if rp.StatusCode == 304 {
 		return rp, nil
 	}
 
 	if rp.StatusCode >= 300 {
 		defer closeBody(rp)
-		return rp, AppErrorFromJSON(rp.Body)
+		return rp, AppErrorFromResponse(rp)
 	}
 
 	return rp, nil
 }
+
+// send performs a single attempt of rq.
+func (c *Client4) send(rq *http.Request) (*http.Response, error) {
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.Wait(rq.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	rp, err := c.transport().RoundTrip(rq)
+
+	if c.RateLimiter != nil && rp != nil {
+		c.RateLimiter.Observe(BuildResponse(rp))
+	}
+	return rp, err
+}
+
 func closeBody(r *http.Response) {
 	if r.Body != nil {
 		_, _ = io.Copy(io.Discard, r.Body)
@@ -0,0 +1,145 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestContext(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	team := s.AddTeam(&mattermost.Team{Name: "team"})
+	ch := s.AddChannel(&mattermost.Channel{TeamId: team.Id, Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	c := s.Client(alice.Id)
+	post, _, err := c.CreateSimpleMessagePost(ch.Id, "hello", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"GetMe", func(ctx context.Context) error {
+			_, _, err := c.GetMeContext(ctx, "")
+			return err
+		}},
+		{"GetUser", func(ctx context.Context) error {
+			_, _, err := c.GetUserContext(ctx, bob.Id, "")
+			return err
+		}},
+		{"GetUserByUsername", func(ctx context.Context) error {
+			_, _, err := c.GetUserByUsernameContext(ctx, "bob", "")
+			return err
+		}},
+		{"GetTeamByName", func(ctx context.Context) error {
+			_, _, err := c.GetTeamByNameContext(ctx, "team", "")
+			return err
+		}},
+		{"GetChannel", func(ctx context.Context) error {
+			_, _, err := c.GetChannelContext(ctx, ch.Id, "")
+			return err
+		}},
+		{"GetChannelMember", func(ctx context.Context) error {
+			_, _, err := c.GetChannelMemberContext(ctx, ch.Id, alice.Id, "")
+			return err
+		}},
+		{"GetChannelMembers", func(ctx context.Context) error {
+			_, _, err := c.GetChannelMembersContext(ctx, ch.Id, 0, 10, "")
+			return err
+		}},
+		{"CreateDirectChannel", func(ctx context.Context) error {
+			_, _, err := c.CreateDirectChannelContext(ctx, alice.Id, bob.Id)
+			return err
+		}},
+		{"CreateSimpleMessagePost", func(ctx context.Context) error {
+			_, _, err := c.CreateSimpleMessagePostContext(ctx, ch.Id, "hi", "")
+			return err
+		}},
+		{"UpdatePost", func(ctx context.Context) error {
+			_, _, err := c.UpdatePostContext(ctx, post.Id, &mattermost.Post{Message: "edited"})
+			return err
+		}},
+		{"UpdateThreadFollowForUser", func(ctx context.Context) error {
+			_, err := c.UpdateThreadFollowForUserContext(ctx, alice.Id, team.Id, post.Id, true)
+			return err
+		}},
+	}
+
+	for _, tc := range calls {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.call(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := tc.call(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("canceled: err = %v", err)
+			}
+		})
+	}
+
+	t.Run("deadline during a request", func(t *testing.T) {
+		c := s.Client(alice.Id)
+		// The server answers after the deadline.
+		c.Use(func(next http.RoundTripper) http.RoundTripper {
+			return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+				if strings.HasSuffix(rq.URL.Path, "/users/me") {
+					<-rq.Context().Done()
+				}
+				return next.RoundTrip(rq)
+			})
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, _, err := c.GetMeContext(ctx, ""); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestNewLocalClient(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+
+	socket := filepath.Join(t.TempDir(), "mattermost.socket")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	srv := &http.Server{Handler: s.Config.Handler}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	c := mattermost.NewLocalClient(socket)
+	if c.URL != mattermost.LocalModeURL || c.APIURL != mattermost.LocalModeURL+mattermost.APIURLSuffix {
+		t.Fatalf("url %s, api url %s", c.URL, c.APIURL)
+	}
+	// Local mode needs no session, but the fake server always does.
+	c.SetToken(s.CreateSession(alice.Id))
+	me, _, err := c.GetMe("")
+	if err != nil || me.Id != alice.Id {
+		t.Fatalf("GetMe = %+v, %v", me, err)
+	}
+
+	t.Run("no socket", func(t *testing.T) {
+		c := mattermost.NewLocalClient(filepath.Join(t.TempDir(), "missing.socket"))
+		if _, _, err := c.GetMe(""); !mattermost.IsNetworkError(err) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package mattermost
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Sentinel errors matched by AppError through errors.Is, for example
+// errors.Is(err, ErrNotFound).
+var (
+	ErrNotFound          = errors.New("mattermost: not found")
+	ErrForbidden         = errors.New("mattermost: forbidden")
+	ErrUnauthorized      = errors.New("mattermost: unauthorized")
+	ErrRateLimited       = errors.New("mattermost: rate limited")
+	ErrServerUnavailable = errors.New("mattermost: server unavailable")
+	ErrDecode            = errors.New("mattermost: cannot decode response")
+)
+
+const (
+	appErrorUnmarshalId  = "api.unmarshal_error"
+	appErrorDecodeJSONId = "model.utils.decode_json.app_error"
+	appErrorHTTPStatusId = "model.client.http_status.app_error"
+
+	maxErrorBodyDetails = 512
+)
+
+// Is reports whether the error belongs to the class described by target.
+func (er *AppError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return er.StatusCode == http.StatusNotFound
+	case ErrForbidden:
+		return er.StatusCode == http.StatusForbidden
+	case ErrUnauthorized:
+		return er.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return er.StatusCode == http.StatusTooManyRequests
+	case ErrServerUnavailable:
+		return er.StatusCode == http.StatusBadGateway ||
+			er.StatusCode == http.StatusServiceUnavailable ||
+			er.StatusCode == http.StatusGatewayTimeout
+	case ErrDecode:
+		return er.Id == appErrorUnmarshalId || er.Id == appErrorDecodeJSONId
+	}
+	return false
+}
+
+// IsAPIError reports whether err was returned by the server, as opposed to a
+// failure to reach it or to decode its answer.
+func IsAPIError(err error) bool {
+	var appErr *AppError
+	return errors.As(err, &appErr) && !errors.Is(appErr, ErrDecode)
+}
+
+// IsNetworkError reports whether err is a transport failure: the request did
+// not reach the server or the connection broke before a response was read.
+func IsNetworkError(err error) bool {
+	var appErr *AppError
+	if err == nil || errors.As(err, &appErr) {
+		return false
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// AppErrorFromResponse builds the error for a failed response and consumes its
+// body. Bodies that are not a JSON AppError, such as HTML pages from a proxy,
+// still produce an error carrying the real status code and request id, unlike
+// AppErrorFromJSON which cannot know them.
+func AppErrorFromResponse(rp *http.Response) *AppError {
+	body, rerr := io.ReadAll(rp.Body)
+
+	var er *AppError
+	if rerr == nil {
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&er); err != nil || er == nil || er.Id == "" {
+			er = nil
+		}
+	}
+	if er == nil {
+		er = NewAppError("DoAPIRequest", appErrorHTTPStatusId, nil, errorBodyDetails(body), rp.StatusCode)
+		er.Message = rp.Status
+		if rerr != nil {
+			er.Wrap(rerr)
+		}
+	}
+
+	er.StatusCode = rp.StatusCode
+	if er.RequestId == "" {
+		er.RequestId = rp.Header.Get(HeaderRequestId)
+	}
+	return er
+}
+
+// errorBodyDetails returns the start of a body that could not be decoded, for
+// AppError.DetailedError.
+func errorBodyDetails(body []byte) string {
+	details := string(body)
+	if len(details) > maxErrorBodyDetails {
+		details = details[:maxErrorBodyDetails]
+	}
+	return "body: " + details
+}
@@ -0,0 +1,101 @@
+package mattermost_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestErrorClassification(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        error
+		wantId      string
+		wantAPI     bool
+	}{
+		{name: "not found", status: http.StatusNotFound, contentType: "application/json", body: `{"id":"app.user.missing_account.const","message":"Unable to find the user.","status_code":404}`, want: mattermost.ErrNotFound, wantId: "app.user.missing_account.const", wantAPI: true},
+		{name: "forbidden", status: http.StatusForbidden, contentType: "application/json", body: `{"id":"api.context.permissions.app_error","status_code":403}`, want: mattermost.ErrForbidden, wantId: "api.context.permissions.app_error", wantAPI: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, contentType: "application/json", body: `{"id":"api.context.session_expired.app_error","status_code":401}`, want: mattermost.ErrUnauthorized, wantId: "api.context.session_expired.app_error", wantAPI: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, contentType: "text/plain", body: "limit exceeded", want: mattermost.ErrRateLimited, wantId: "model.client.http_status.app_error", wantAPI: true},
+		{name: "HTML page from a proxy", status: http.StatusBadGateway, contentType: "text/html", body: "<html><body>Bad Gateway</body></html>", want: mattermost.ErrServerUnavailable, wantId: "model.client.http_status.app_error", wantAPI: true},
+		{name: "JSON without an error id", status: http.StatusServiceUnavailable, contentType: "application/json", body: `{"status":"down"}`, want: mattermost.ErrServerUnavailable, wantId: "model.client.http_status.app_error", wantAPI: true},
+		{name: "undecodable success", status: http.StatusOK, contentType: "application/json", body: `{"id":`, want: mattermost.ErrDecode, wantId: "api.unmarshal_error"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(mattermost.HeaderRequestId, "request-id")
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.status)
+				_, _ = io.WriteString(w, tc.body)
+			}))
+			defer srv.Close()
+
+			_, _, err := mattermost.NewAPIv4Client(srv.URL).GetUser("user-id", "")
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			var appErr *mattermost.AppError
+			if !errors.As(err, &appErr) || appErr.Id != tc.wantId {
+				t.Fatalf("err = %#v, want id %q", err, tc.wantId)
+			}
+			if tc.status != http.StatusOK && (appErr.StatusCode != tc.status || appErr.RequestId != "request-id") {
+				t.Fatalf("status = %d, request id = %q", appErr.StatusCode, appErr.RequestId)
+			}
+			if mattermost.IsAPIError(err) != tc.wantAPI || mattermost.IsNetworkError(err) {
+				t.Fatalf("IsAPIError = %v, IsNetworkError = %v", mattermost.IsAPIError(err), mattermost.IsNetworkError(err))
+			}
+		})
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	_, _, err := mattermost.NewAPIv4Client(srv.URL).GetUser("user-id", "")
+	if !mattermost.IsNetworkError(err) || mattermost.IsAPIError(err) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestErrorFromFakeServer(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "someone"}, "password")
+
+	_, _, err := s.Client(u.Id).GetUser(mattermosttest.NewId(), "")
+	var appErr *mattermost.AppError
+	if !errors.Is(err, mattermost.ErrNotFound) || !errors.As(err, &appErr) || appErr.RequestId == "" {
+		t.Fatalf("err = %#v", err)
+	}
+}
+
+func TestAppErrorFromResponse(t *testing.T) {
+	rp := &http.Response{
+		Status:     "502 Bad Gateway",
+		StatusCode: http.StatusBadGateway,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("<html>" + strings.Repeat("x", 2000) + "</html>")),
+	}
+	rp.Header.Set(mattermost.HeaderRequestId, "request-id")
+	appErr := mattermost.AppErrorFromResponse(rp)
+	if appErr.StatusCode != http.StatusBadGateway || appErr.RequestId != "request-id" || appErr.Message != rp.Status {
+		t.Fatalf("err = %#v", appErr)
+	}
+	if len(appErr.DetailedError) > 600 {
+		t.Fatalf("details not truncated: %d bytes", len(appErr.DetailedError))
+	}
+
+	if err := mattermost.AppErrorFromJSON(strings.NewReader("<html>")); !errors.Is(err, mattermost.ErrDecode) {
+		t.Fatalf("AppErrorFromJSON = %#v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type FileInfo struct {
+	Id              string  `json:"id"`
+	CreatorId       string  `json:"user_id"`
+	PostId          string  `json:"post_id,omitempty"`
+	ChannelId       string  `json:"channel_id"`
+	CreateAt        int64   `json:"create_at"`
+	UpdateAt        int64   `json:"update_at"`
+	DeleteAt        int64   `json:"delete_at"`
+	Name            string  `json:"name"`
+	Extension       string  `json:"extension"`
+	Size            int64   `json:"size"`
+	MimeType        string  `json:"mime_type"`
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	HasPreviewImage bool    `json:"has_preview_image,omitempty"`
+	MiniPreview     *[]byte `json:"mini_preview"` // declared as *[]byte to avoid postgres/mysql differences in deserialization
+	RemoteId        *string `json:"remote_id"`
+	Archived        bool    `json:"archived"`
+}
+
+type FileUploadResponse struct {
+	FileInfos []*FileInfo `json:"file_infos"`
+	ClientIds []string    `json:"client_ids"`
+}
+
+// FileUpload is a file to attach to a post with CreatePostWithFiles.
+type FileUpload struct {
+	Name string
+	Data io.Reader
+}
+
+// UploadFile uploads a file to a channel, to be attached to a post through its
+// FileIds. data is streamed to the server as it is read, so large files are
+// never held in memory. Such uploads cannot be retried; use UploadLargeFile
+// for files that may need to be resumed.
+func (c *Client4) UploadFile(channelId string, filename string, data io.Reader) (*FileUploadResponse, *Response, error) {
+	return c.UploadFileContext(context.Background(), channelId, filename, data)
+}
+
+func (c *Client4) UploadFileContext(ctx context.Context, channelId string, filename string, data io.Reader) (*FileUploadResponse, *Response, error) {
+	pr, pw := io.Pipe()
+	// Closing the reader stops the writer when the request ends early.
+	defer pr.Close()
+
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeFileMultipart(mw, channelId, filename, data))
+	}()
+
+	r, err := c.DoAPIRequestReaderContext(ctx, http.MethodPost, c.APIURL+c.filesRoute(), pr, map[string]string{"Content-Type": mw.FormDataContentType()})
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var res FileUploadResponse
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return nil, nil, NewAppError("UploadFile", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &res, BuildResponse(r), nil
+}
+
+// writeFileMultipart writes the upload form. The server streams the files to
+// storage as they arrive, so channel_id must come before them.
+func writeFileMultipart(mw *multipart.Writer, channelId, filename string, data io.Reader) error {
+	if err := mw.WriteField("channel_id", channelId); err != nil {
+		return err
+	}
+	part, err := mw.CreateFormFile("files", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, data); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// CreatePostWithFiles uploads files to post.ChannelId and creates the post with
+// them attached. The ids of the uploaded files are appended to post.FileIds.
+func (c *Client4) CreatePostWithFiles(post *Post, files ...FileUpload) (*Post, *Response, error) {
+	return c.CreatePostWithFilesContext(context.Background(), post, files...)
+}
+
+func (c *Client4) CreatePostWithFilesContext(ctx context.Context, post *Post, files ...FileUpload) (*Post, *Response, error) {
+	for _, f := range files {
+		res, r, err := c.UploadFileContext(ctx, post.ChannelId, f.Name, f.Data)
+		if err != nil {
+			return nil, r, err
+		}
+		for _, info := range res.FileInfos {
+			post.FileIds = append(post.FileIds, info.Id)
+		}
+	}
+	return c.CreatePostContext(ctx, post)
+}
+
+// GetFile downloads the content of a file. Use DownloadFile to stream large
+// files to a writer instead of holding them in memory.
+func (c *Client4) GetFile(fileId string) ([]byte, *Response, error) {
+	return c.GetFileContext(context.Background(), fileId)
+}
+
+func (c *Client4) GetFileContext(ctx context.Context, fileId string) ([]byte, *Response, error) {
+	return c.getFileBytes(ctx, "GetFile", c.fileRoute(fileId))
+}
+
+// GetFileThumbnail downloads the thumbnail of an image file.
+func (c *Client4) GetFileThumbnail(fileId string) ([]byte, *Response, error) {
+	return c.GetFileThumbnailContext(context.Background(), fileId)
+}
+
+func (c *Client4) GetFileThumbnailContext(ctx context.Context, fileId string) ([]byte, *Response, error) {
+	return c.getFileBytes(ctx, "GetFileThumbnail", c.fileRoute(fileId)+"/thumbnail")
+}
+
+// GetFilePreview downloads the preview of an image file.
+func (c *Client4) GetFilePreview(fileId string) ([]byte, *Response, error) {
+	return c.GetFilePreviewContext(context.Background(), fileId)
+}
+
+func (c *Client4) GetFilePreviewContext(ctx context.Context, fileId string) ([]byte, *Response, error) {
+	return c.getFileBytes(ctx, "GetFilePreview", c.fileRoute(fileId)+"/preview")
+}
+
+func (c *Client4) getFileBytes(ctx context.Context, where, route string) ([]byte, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, route, "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, BuildResponse(r), NewAppError(where, "model.client.read_file.app_error", nil, "", r.StatusCode).Wrap(err)
+	}
+	return data, BuildResponse(r), nil
+}
+
+func (c *Client4) GetFileInfo(fileId string) (*FileInfo, *Response, error) {
+	return c.GetFileInfoContext(context.Background(), fileId)
+}
+
+func (c *Client4) GetFileInfoContext(ctx context.Context, fileId string) (*FileInfo, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.fileRoute(fileId)+"/info", "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var info FileInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		return nil, nil, NewAppError("GetFileInfo", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &info, BuildResponse(r), nil
+}
+
+// GetFileLink gets a public link to a file, which requires public links to be
+// enabled on the server.
+func (c *Client4) GetFileLink(fileId string) (string, *Response, error) {
+	return c.GetFileLinkContext(context.Background(), fileId)
+}
+
+func (c *Client4) GetFileLinkContext(ctx context.Context, fileId string) (string, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.fileRoute(fileId)+"/link", "")
+	if err != nil {
+		return "", BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var res struct {
+		Link string `json:"link"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return "", nil, NewAppError("GetFileLink", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return res.Link, BuildResponse(r), nil
+}
+
+// DownloadFile writes the content of a file to w. When the transfer breaks,
+// it continues from the last byte written with a Range request instead of
+// starting over, following the client's RetryPolicy, or the default policy,
+// for the number of attempts without progress. The length written is checked
+// against FileInfo.Size, which is returned.
+func (c *Client4) DownloadFile(fileId string, w io.Writer) (*FileInfo, error) {
+	return c.DownloadFileContext(context.Background(), fileId, w)
+}
+
+func (c *Client4) DownloadFileContext(ctx context.Context, fileId string, w io.Writer) (*FileInfo, error) {
+	info, _, err := c.GetFileInfoContext(ctx, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := c.RetryPolicy
+	if policy == nil {
+		policy = NewRetryPolicy()
+	}
+	// The loop below does the retrying, so the range requests are sent once.
+	rctx := withoutRetries(ctx)
+
+	var written int64
+	failures := 0
+	for written < info.Size {
+		n, r, err := c.downloadRange(rctx, fileId, written, w)
+		written += n
+		if err == nil {
+			break
+		}
+		if n > 0 {
+			failures = 0
+		}
+		var writeErr *downloadWriteError
+		if errors.As(err, &writeErr) {
+			return nil, writeErr.err
+		}
+		if !IsNetworkError(err) && !errors.Is(err, ErrServerUnavailable) && !errors.Is(err, ErrRateLimited) {
+			return nil, err
+		}
+		failures++
+		if failures >= policy.MaxAttempts {
+			return nil, err
+		}
+		var rp *http.Response
+		if r != nil {
+			rp = &http.Response{StatusCode: r.StatusCode, Header: r.Header}
+		}
+		delay, ok := policy.backoff(failures, rp)
+		if !ok {
+			return nil, err
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	if written != info.Size {
+		return nil, NewAppError("DownloadFile", "model.client.download_file.size_mismatch.app_error", nil,
+			"expected="+strconv.FormatInt(info.Size, 10)+", received="+strconv.FormatInt(written, 10), http.StatusInternalServerError)
+	}
+	return info, nil
+}
+
+// downloadWriteError marks a failure of the destination writer, which is not
+// worth retrying.
+type downloadWriteError struct {
+	err error
+}
+
+func (e *downloadWriteError) Error() string { return e.err.Error() }
+
+// downloadRange copies the file from offset to w and returns the number of
+// bytes written, with the response when the server refused the request.
+func (c *Client4) downloadRange(ctx context.Context, fileId string, offset int64, w io.Writer) (int64, *Response, error) {
+	headers := map[string]string{}
+	if offset > 0 {
+		headers[HeaderRange] = "bytes=" + strconv.FormatInt(offset, 10) + "-"
+	}
+	r, err := c.DoAPIRequestReaderContext(ctx, http.MethodGet, c.APIURL+c.fileRoute(fileId), nil, headers)
+	if err != nil {
+		return 0, BuildResponse(r), err
+	}
+	defer closeBody(r)
+
+	if offset > 0 {
+		switch {
+		case r.StatusCode == http.StatusOK:
+			// The server ignored the range: skip what was already written.
+			if _, err := io.CopyN(io.Discard, r.Body, offset); err != nil {
+				return 0, nil, err
+			}
+		case !strings.HasPrefix(r.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(offset, 10)+"-"):
+			return 0, nil, NewAppError("DownloadFile", "model.client.download_file.invalid_range.app_error", nil,
+				"content_range="+r.Header.Get("Content-Range"), http.StatusInternalServerError)
+		}
+	}
+
+	n, err := io.Copy(writerFunc(func(p []byte) (int, error) {
+		n, err := w.Write(p)
+		if err != nil {
+			err = &downloadWriteError{err}
+		}
+		return n, err
+	}), r.Body)
+	return n, nil, err
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,369 @@
+package mattermost_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+// fileServer returns a server with alice in a channel, and a private channel
+// she is not a member of.
+func fileServer(t *testing.T) (s *mattermosttest.Server, c *mattermost.Client4, ch, private *mattermost.Channel) {
+	t.Helper()
+	s = mattermosttest.NewServer()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	ch = s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	private = s.AddChannel(&mattermost.Channel{Name: "private", Type: mattermost.ChannelTypePrivate})
+	s.AddChannelMember(ch.Id, alice.Id)
+	return s, s.Client(alice.Id), ch, private
+}
+
+// failingReader returns data and then err.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUploadFile(t *testing.T) {
+	s, c, ch, private := fileServer(t)
+	defer s.Close()
+
+	for _, tc := range []struct {
+		name      string
+		channelId string
+		filename  string
+		data      io.Reader
+		want      []byte
+		wantMime  string
+		wantErr   error
+	}{
+		{name: "text", channelId: ch.Id, filename: "notes.txt", data: strings.NewReader("hello"), want: []byte("hello"), wantMime: "text/plain; charset=utf-8"},
+		{name: "binary", channelId: ch.Id, filename: "image.png", data: bytes.NewReader(pngData), want: pngData, wantMime: "image/png"},
+		{name: "no extension", channelId: ch.Id, filename: "README", data: strings.NewReader("<html></html>"), want: []byte("<html></html>"), wantMime: "text/html; charset=utf-8"},
+		{name: "empty", channelId: ch.Id, filename: "empty.txt", data: strings.NewReader(""), want: []byte{}, wantMime: "text/plain; charset=utf-8"},
+		{name: "not a member", channelId: private.Id, filename: "notes.txt", data: strings.NewReader("hello"), wantErr: mattermost.ErrForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, _, err := c.UploadFile(tc.channelId, tc.filename, tc.data)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.FileInfos) != 1 {
+				t.Fatalf("file infos %+v", res.FileInfos)
+			}
+			info, data := s.File(res.FileInfos[0].Id)
+			if info == nil || !bytes.Equal(data, tc.want) {
+				t.Fatalf("stored %+v, %q, want %q", info, data, tc.want)
+			}
+			if info.Name != tc.filename || info.ChannelId != tc.channelId || info.Size != int64(len(tc.want)) || info.MimeType != tc.wantMime {
+				t.Fatalf("info %+v", info)
+			}
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		readErr := errors.New("disk failure")
+		if _, _, err := c.UploadFile(ch.Id, "broken.txt", &failingReader{data: []byte("partial"), err: readErr}); !errors.Is(err, readErr) {
+			t.Fatalf("err = %v, want %v", err, readErr)
+		}
+	})
+}
+
+func TestCreatePostWithFiles(t *testing.T) {
+	s, c, ch, private := fileServer(t)
+	defer s.Close()
+
+	post, _, err := c.CreatePostWithFiles(&mattermost.Post{ChannelId: ch.Id, Message: "files"},
+		mattermost.FileUpload{Name: "a.txt", Data: strings.NewReader("a")},
+		mattermost.FileUpload{Name: "b.png", Data: bytes.NewReader(pngData)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(post.FileIds) != 2 {
+		t.Fatalf("file ids %v", post.FileIds)
+	}
+	for i, name := range []string{"a.txt", "b.png"} {
+		info, _ := s.File(post.FileIds[i])
+		if info == nil || info.Name != name || info.PostId != post.Id {
+			t.Fatalf("file %d: %+v", i, info)
+		}
+	}
+
+	if _, _, err := c.CreatePostWithFiles(&mattermost.Post{ChannelId: private.Id},
+		mattermost.FileUpload{Name: "a.txt", Data: strings.NewReader("a")},
+	); !errors.Is(err, mattermost.ErrForbidden) {
+		t.Fatalf("err = %v", err)
+	}
+	if posts := s.Posts(private.Id); len(posts) != 0 {
+		t.Fatalf("posts %v", posts)
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	s, c, ch, _ := fileServer(t)
+	defer s.Close()
+	text, _, err := c.UploadFile(ch.Id, "notes.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, _, err := c.UploadFile(ch.Id, "image.png", bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	textId, imageId := text.FileInfos[0].Id, image.FileInfos[0].Id
+
+	for _, tc := range []struct {
+		name       string
+		get        func(fileId string) ([]byte, *mattermost.Response, error)
+		fileId     string
+		want       []byte
+		wantStatus int
+	}{
+		{name: "file", get: c.GetFile, fileId: textId, want: []byte("hello")},
+		{name: "binary file", get: c.GetFile, fileId: imageId, want: pngData},
+		{name: "thumbnail", get: c.GetFileThumbnail, fileId: imageId, want: pngData},
+		{name: "preview", get: c.GetFilePreview, fileId: imageId, want: pngData},
+		{name: "no thumbnail", get: c.GetFileThumbnail, fileId: textId, wantStatus: http.StatusBadRequest},
+		{name: "unknown file", get: c.GetFile, fileId: mattermosttest.NewId(), wantStatus: http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, _, err := tc.get(tc.fileId)
+			if tc.wantStatus != 0 {
+				var appErr *mattermost.AppError
+				if !errors.As(err, &appErr) || appErr.StatusCode != tc.wantStatus {
+					t.Fatalf("err = %v, want status %d", err, tc.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tc.want) {
+				t.Fatalf("data %q, want %q", data, tc.want)
+			}
+		})
+	}
+
+	t.Run("info", func(t *testing.T) {
+		info, _, err := c.GetFileInfo(imageId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name != "image.png" || info.Extension != "png" || !info.HasPreviewImage || info.Size != int64(len(pngData)) {
+			t.Fatalf("info %+v", info)
+		}
+	})
+
+	t.Run("link", func(t *testing.T) {
+		link, _, err := c.GetFileLink(textId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(link, s.URL+"/files/"+textId+"/public?h=") {
+			t.Fatalf("link %q", link)
+		}
+	})
+}
+
+// fileDownloads passes the responses to file content requests to edit,
+// numbered from 1, and records their Range headers.
+func fileDownloads(mu *sync.Mutex, ranges *[]string, edit func(n int, rq *http.Request, rp *http.Response)) mattermost.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+			if rq.Method != http.MethodGet || !strings.Contains(rq.URL.Path, "/files/") || strings.HasSuffix(rq.URL.Path, "/info") {
+				return next.RoundTrip(rq)
+			}
+			mu.Lock()
+			*ranges = append(*ranges, rq.Header.Get(mattermost.HeaderRange))
+			n := len(*ranges)
+			mu.Unlock()
+			rp, err := next.RoundTrip(rq)
+			if err == nil {
+				edit(n, rq, rp)
+			}
+			return rp, err
+		})
+	}
+}
+
+// cutBody makes rp end after n bytes of its body with err, or cleanly when
+// err is nil.
+func cutBody(rp *http.Response, n int64, err error) {
+	r := io.LimitReader(rp.Body, n)
+	if err != nil {
+		r = io.MultiReader(r, &failingReader{err: err})
+	}
+	rp.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, rp.Body}
+}
+
+// failingWriter accepts n bytes and then fails.
+type failingWriter struct {
+	n   int
+	err error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, w.err
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestDownloadFile(t *testing.T) {
+	s, c, ch, _ := fileServer(t)
+	defer s.Close()
+	data := testData(1000)
+	res, _, err := c.UploadFile(ch.Id, "data.bin", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileId := res.FileInfos[0].Id
+	writeErr := errors.New("disk full")
+
+	for _, tc := range []struct {
+		name       string
+		edit       func(n int, rq *http.Request, rp *http.Response)
+		w          io.Writer
+		wantRanges []string
+		wantErr    error
+		wantId     string
+	}{
+		{name: "whole", edit: func(int, *http.Request, *http.Response) {}, wantRanges: []string{""}},
+		{
+			name: "resumed",
+			edit: func(n int, _ *http.Request, rp *http.Response) {
+				if n <= 2 {
+					cutBody(rp, 300, io.ErrUnexpectedEOF)
+				}
+			},
+			wantRanges: []string{"", "bytes=300-", "bytes=600-"},
+		},
+		{
+			name: "server ignores the range",
+			edit: func(n int, rq *http.Request, rp *http.Response) {
+				if n == 1 {
+					cutBody(rp, 300, io.ErrUnexpectedEOF)
+					return
+				}
+				// Serve the whole file, as a server without Range support.
+				rp.StatusCode = http.StatusOK
+				rp.Header.Del("Content-Range")
+				rp.Body = io.NopCloser(bytes.NewReader(data))
+			},
+			wantRanges: []string{"", "bytes=300-"},
+		},
+		{
+			name: "no progress",
+			edit: func(n int, _ *http.Request, rp *http.Response) {
+				if n == 1 {
+					cutBody(rp, 300, io.ErrUnexpectedEOF)
+				} else {
+					cutBody(rp, 0, io.ErrUnexpectedEOF)
+				}
+			},
+			wantRanges: []string{"", "bytes=300-", "bytes=300-"},
+			wantErr:    io.ErrUnexpectedEOF,
+		},
+		{
+			// The client does not retry the requests again on its own.
+			name: "server unavailable",
+			edit: func(_ int, rq *http.Request, rp *http.Response) {
+				rp.Body.Close()
+				*rp = *unavailable(rq)
+			},
+			wantRanges: []string{"", "", ""},
+			wantErr:    mattermost.ErrServerUnavailable,
+		},
+		{
+			name:       "writer fails",
+			edit:       func(int, *http.Request, *http.Response) {},
+			w:          &failingWriter{n: 100, err: writeErr},
+			wantRanges: []string{""},
+			wantErr:    writeErr,
+		},
+		{
+			name:       "short body",
+			edit:       func(_ int, _ *http.Request, rp *http.Response) { cutBody(rp, 999, nil) },
+			wantRanges: []string{""},
+			wantId:     "model.client.download_file.size_mismatch.app_error",
+		},
+		{
+			name: "wrong range",
+			edit: func(n int, _ *http.Request, rp *http.Response) {
+				if n == 1 {
+					cutBody(rp, 300, io.ErrUnexpectedEOF)
+					return
+				}
+				rp.Header.Set("Content-Range", "bytes 0-999/"+strconv.Itoa(len(data)))
+			},
+			wantRanges: []string{"", "bytes=300-"},
+			wantId:     "model.client.download_file.invalid_range.app_error",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := s.Client(res.FileInfos[0].CreatorId)
+			c.RetryPolicy = fastRetries
+			var mu sync.Mutex
+			var ranges []string
+			c.Use(fileDownloads(&mu, &ranges, tc.edit))
+			var buf bytes.Buffer
+			w := tc.w
+			if w == nil {
+				w = &buf
+			}
+
+			info, err := c.DownloadFile(fileId, w)
+			if !slices.Equal(ranges, tc.wantRanges) {
+				t.Errorf("ranges %q, want %q", ranges, tc.wantRanges)
+			}
+			var appErr *mattermost.AppError
+			switch {
+			case tc.wantErr != nil:
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+			case tc.wantId != "":
+				if !errors.As(err, &appErr) || appErr.Id != tc.wantId {
+					t.Fatalf("err = %v, want %s", err, tc.wantId)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case info.Id != fileId || !bytes.Equal(buf.Bytes(), data):
+				t.Fatalf("info %+v, %d bytes", info, buf.Len())
+			}
+		})
+	}
+}
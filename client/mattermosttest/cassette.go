@@ -0,0 +1,366 @@
+package mattermosttest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// Redacted replaces secrets in recorded traffic.
+const Redacted = "REDACTED"
+
+// BodyEncodingBase64 marks a recorded body that is not valid UTF-8, such as a
+// downloaded file, and is stored base64 encoded.
+const BodyEncodingBase64 = "base64"
+
+// multipartBoundary replaces the random boundary of recorded multipart bodies
+// so that the same form matches when it is sent again.
+const multipartBoundary = "mattermosttest"
+
+var (
+	redactedHeaders = []string{mattermost.HeaderAuth, mattermost.HeaderToken, mattermost.HeaderCsrfToken, "Cookie", "Set-Cookie"}
+
+	// redactedFields are the JSON and form fields holding secrets, such as
+	// User.Password and User.MfaSecret.
+	redactedFields = map[string]bool{
+		"password":      true,
+		"mfa_secret":    true,
+		"token":         true,
+		"client_secret": true,
+		"access_token":  true,
+		"refresh_token": true,
+	}
+)
+
+// ErrNoInteraction is returned in replay mode when no recorded interaction
+// matches a request.
+var ErrNoInteraction = errors.New("mattermosttest: no recorded interaction matches the request")
+
+type CassetteMode int
+
+const (
+	ModeRecord CassetteMode = iota // Send requests to the server and record them
+	ModeReplay                     // Answer requests from the recording only
+)
+
+type RecordedRequest struct {
+	Method       string      `json:"method"`
+	Path         string      `json:"path"`
+	Query        string      `json:"query,omitempty"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"` // Empty for UTF-8 text, or BodyEncodingBase64
+}
+
+type RecordedResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"` // Empty for UTF-8 text, or BodyEncodingBase64
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette is an http.RoundTripper that records Client4 traffic to a file and
+// replays it deterministically. Secrets are redacted before anything is kept.
+// Requests match a recorded interaction on method, path, query and body;
+// identical requests are answered in the order they were recorded. Multipart
+// bodies are recorded with a fixed boundary so that uploads match on replay.
+//
+//	cassette, err := mattermosttest.NewCassette("testdata/get_me.json", mattermosttest.ModeReplay, nil)
+//	client.HTTPClient.Transport = cassette
+type Cassette struct {
+	path      string
+	mode      CassetteMode
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewCassette opens the cassette at path. In ModeReplay the file must exist;
+// in ModeRecord requests are sent with transport, or http.DefaultTransport when
+// nil, and Save writes them to path.
+func NewCassette(path string, mode CassetteMode, transport http.RoundTripper) (*Cassette, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c := &Cassette{path: path, mode: mode, transport: transport}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("mattermosttest: invalid cassette %s: %w", path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+	}
+	return c, nil
+}
+
+// Interactions returns the recorded interactions.
+func (c *Cassette) Interactions() []*Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Interaction(nil), c.interactions...)
+}
+
+// Save writes the recorded interactions to the cassette file.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *Cassette) RoundTrip(rq *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.mode == ModeReplay {
+		return c.replay(rq, recorded)
+	}
+	return c.record(rq, recorded)
+}
+
+func (c *Cassette) record(rq *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	rp, err := c.transport.RoundTrip(rq)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(rp.Body)
+	_ = rp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header, recordedBody, encoding := recordBody(rp.Header, body)
+	c.mu.Lock()
+	c.interactions = append(c.interactions, &Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode:   rp.StatusCode,
+			Header:       header,
+			Body:         recordedBody,
+			BodyEncoding: encoding,
+		},
+	})
+	c.mu.Unlock()
+	return rp, nil
+}
+
+func (c *Cassette) replay(rq *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, in := range c.interactions {
+		if c.used[i] || !matches(in.Request, recorded) {
+			continue
+		}
+		body, err := decodeRecordedBody(in.Response.Body, in.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		c.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       rq,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, rq.Method, rq.URL.RequestURI())
+}
+
+func matches(a, b RecordedRequest) bool {
+	return a.Method == b.Method && a.Path == b.Path && a.Query == b.Query && a.Body == b.Body && a.BodyEncoding == b.BodyEncoding
+}
+
+// recordRequest captures rq with secrets redacted, leaving its body readable.
+func recordRequest(rq *http.Request) (RecordedRequest, error) {
+	var body []byte
+	if rq.Body != nil && rq.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(rq.Body)
+		_ = rq.Body.Close()
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+		rq.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	query := rq.URL.Query()
+	for k := range query {
+		if redactedFields[k] {
+			query.Set(k, Redacted)
+		}
+	}
+
+	header, recordedBody, encoding := recordBody(rq.Header, body)
+	return RecordedRequest{
+		Method:       rq.Method,
+		Path:         rq.URL.Path,
+		Query:        query.Encode(),
+		Header:       header,
+		Body:         recordedBody,
+		BodyEncoding: encoding,
+	}, nil
+}
+
+// recordBody returns the redacted header and body of a request or response as
+// stored in the cassette. Bodies that are not valid UTF-8 are base64 encoded.
+func recordBody(h http.Header, body []byte) (header http.Header, recorded, encoding string) {
+	header = redactHeader(h)
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		if normalized, err := normalizeMultipart(body, params["boundary"]); err == nil {
+			body = normalized
+			header.Set("Content-Type", mime.FormatMediaType(mediaType, map[string]string{"boundary": multipartBoundary}))
+		}
+	} else {
+		body = redactBody(mediaType, body)
+	}
+
+	if utf8.Valid(body) {
+		return header, string(body), ""
+	}
+	return header, base64.StdEncoding.EncodeToString(body), BodyEncodingBase64
+}
+
+func decodeRecordedBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case BodyEncodingBase64:
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("mattermosttest: unknown body encoding %q", encoding)
+	}
+}
+
+// normalizeMultipart rewrites a multipart body with multipartBoundary and the
+// secret form fields redacted.
+func normalizeMultipart(body []byte, boundary string) ([]byte, error) {
+	r := multipart.NewReader(bytes.NewReader(body), boundary)
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(multipartBoundary); err != nil {
+		return nil, err
+	}
+	for {
+		part, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() == "" && redactedFields[part.FormName()] {
+			data = []byte(Redacted)
+		}
+		pw, err := w.CreatePart(part.Header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range redactedHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, Redacted)
+		}
+	}
+	return h
+}
+
+// redactBody blanks secret fields of JSON and form bodies. JSON is also
+// re-encoded so that equivalent bodies compare equal when matching.
+func redactBody(mediaType string, body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for k := range form {
+				if redactedFields[k] {
+					form.Set(k, Redacted)
+				}
+			}
+			return []byte(form.Encode())
+		}
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func redactJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if s, ok := val.(string); ok && redactedFields[k] && s != "" {
+				t[k] = Redacted
+			} else {
+				t[k] = redactJSON(val)
+			}
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = redactJSON(val)
+		}
+	}
+	return v
+}
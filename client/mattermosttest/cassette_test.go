@@ -0,0 +1,122 @@
+package mattermosttest_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestCassette(t *testing.T) {
+	binary := make([]byte, 1024)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// session runs the same calls against the server while recording and
+	// against the cassette alone while replaying.
+	session := func(t *testing.T, c *mattermost.Client4, channelId string) {
+		t.Helper()
+		if _, _, err := c.Login("alice", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		uploaded, _, err := c.UploadFile(channelId, "blob.bin", bytes.NewReader(binary))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _, err := c.GetFile(uploaded.FileInfos[0].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, binary) {
+			t.Fatal("downloaded file differs from the upload")
+		}
+	}
+
+	s := mattermosttest.NewServer()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+
+	recorder, err := mattermosttest.NewCassette(path, mattermosttest.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := mattermost.NewAPIv4Client(s.URL)
+	c.HTTPClient.Transport = recorder
+	session(t, c, ch.Id)
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	token := c.AuthToken
+	s.Close()
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"secret", token} {
+			if strings.Contains(string(data), secret) {
+				t.Fatalf("cassette contains %q", secret)
+			}
+		}
+		login := recorder.Interactions()[0]
+		if !strings.Contains(login.Request.Body, mattermosttest.Redacted) || login.Response.Header.Get(mattermost.HeaderToken) != mattermosttest.Redacted {
+			t.Fatalf("login recorded as %+v", login)
+		}
+	})
+
+	t.Run("binary bodies are base64 encoded", func(t *testing.T) {
+		var upload, download *mattermosttest.Interaction
+		for _, in := range recorder.Interactions() {
+			switch {
+			case in.Request.Method == "POST" && strings.HasSuffix(in.Request.Path, "/files"):
+				upload = in
+			case in.Request.Method == "GET" && strings.Contains(in.Request.Path, "/files/"):
+				download = in
+			}
+		}
+		if upload == nil || upload.Request.BodyEncoding != mattermosttest.BodyEncodingBase64 {
+			t.Fatalf("upload recorded as %+v", upload)
+		}
+		if !strings.Contains(upload.Request.Header.Get("Content-Type"), "boundary=mattermosttest") {
+			t.Fatalf("upload content type %q", upload.Request.Header.Get("Content-Type"))
+		}
+		if download == nil || download.Response.BodyEncoding != mattermosttest.BodyEncodingBase64 {
+			t.Fatalf("download recorded as %+v", download)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		player, err := mattermosttest.NewCassette(path, mattermosttest.ModeReplay, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := mattermost.NewAPIv4Client("http://cassette.invalid")
+		c.HTTPClient.Transport = player
+		session(t, c, ch.Id)
+
+		if _, _, err := c.GetMe(""); !errors.Is(err, mattermosttest.ErrNoInteraction) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+
+	t.Run("uploads of other content do not match", func(t *testing.T) {
+		player, err := mattermosttest.NewCassette(path, mattermosttest.ModeReplay, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := mattermost.NewAPIv4Client("http://cassette.invalid")
+		c.HTTPClient.Transport = player
+		if _, _, err := c.UploadFile(ch.Id, "blob.bin", strings.NewReader("other")); !errors.Is(err, mattermosttest.ErrNoInteraction) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package mattermosttest
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+type storedFile struct {
+	info *mattermost.FileInfo
+	data []byte
+}
+
+type upload struct {
+	session *mattermost.UploadSession
+	data    bytes.Buffer
+}
+
+// File returns a copy of an uploaded file and its content, or nil when there
+// is no such file.
+func (s *Server) File(fileId string) (*mattermost.FileInfo, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[fileId]
+	if !ok {
+		return nil, nil
+	}
+	info := *f.info
+	return &info, bytes.Clone(f.data)
+}
+
+// storeFile saves data as a file of channelId. s.mu must be held.
+func (s *Server) storeFile(userId, channelId, name string, data []byte) *mattermost.FileInfo {
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	info := &mattermost.FileInfo{
+		Id:        NewId(),
+		CreatorId: userId,
+		ChannelId: channelId,
+		CreateAt:  now(),
+		Name:      name,
+		Extension: strings.ToLower(ext),
+		Size:      int64(len(data)),
+		MimeType:  mime.TypeByExtension("." + ext),
+	}
+	info.UpdateAt = info.CreateAt
+	if info.MimeType == "" {
+		info.MimeType = http.DetectContentType(data)
+	}
+	info.HasPreviewImage = strings.HasPrefix(info.MimeType, "image/")
+	s.files[info.Id] = &storedFile{info: info, data: data}
+	res := *info
+	return &res
+}
+
+// canPost reports whether userId is a member of channelId. s.mu must be held.
+func (s *Server) canPost(userId, channelId string) bool {
+	return s.members[channelId][userId] != nil
+}
+
+func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request, userId string) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, r, "api.file.upload_file.read_request.app_error", "Unable to read the request.", http.StatusBadRequest)
+		return
+	}
+
+	var channelId string
+	res := &mattermost.FileUploadResponse{FileInfos: []*mattermost.FileInfo{}, ClientIds: []string{}}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, r, "api.file.upload_file.read_request.app_error", "Unable to read the request.", http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			writeError(w, r, "api.file.upload_file.read_request.app_error", "Unable to read the request.", http.StatusBadRequest)
+			return
+		}
+
+		switch part.FormName() {
+		case "channel_id":
+			channelId = string(data)
+		case "client_ids":
+			res.ClientIds = append(res.ClientIds, string(data))
+		case "files":
+			// As on a real server, the channel must be known before the files.
+			s.mu.Lock()
+			if channelId == "" || !s.canPost(userId, channelId) {
+				s.mu.Unlock()
+				writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+				return
+			}
+			res.FileInfos = append(res.FileInfos, s.storeFile(userId, channelId, part.FileName(), data))
+			s.mu.Unlock()
+		}
+	}
+	if len(res.FileInfos) == 0 {
+		writeError(w, r, "api.file.upload_file.no_files.app_error", "No files in the request.", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, userId string) {
+	var us mattermost.UploadSession
+	if !decodeBody(w, r, &us) {
+		return
+	}
+	if us.Type != mattermost.UploadTypeAttachment || us.Filename == "" || us.FileSize <= 0 {
+		writeError(w, r, "model.upload_session.is_valid.app_error", "Invalid upload session.", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.canPost(userId, us.ChannelId) {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+	us.Id = NewId()
+	us.UserId = userId
+	us.CreateAt = now()
+	us.FileOffset = 0
+	s.uploads[us.Id] = &upload{session: &us}
+	res := us
+	writeJSON(w, http.StatusCreated, &res)
+}
+
+// ownUpload returns the upload session if it belongs to userId. s.mu must be held.
+func (s *Server) ownUpload(w http.ResponseWriter, r *http.Request, userId string) *upload {
+	u, ok := s.uploads[r.PathValue("upload_id")]
+	if !ok || u.session.UserId != userId {
+		writeError(w, r, "app.upload.get.app_error", "Unable to find the upload session.", http.StatusNotFound)
+		return nil
+	}
+	return u
+}
+
+func (s *Server) getUpload(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.ownUpload(w, r, userId); u != nil {
+		res := *u.session
+		writeJSON(w, http.StatusOK, &res)
+	}
+}
+
+func (s *Server) uploadData(w http.ResponseWriter, r *http.Request, userId string) {
+	// Data received before a broken connection is kept, like on a real
+	// server, so that the client has to resume from the reported offset.
+	data, readErr := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.ownUpload(w, r, userId)
+	if u == nil {
+		return
+	}
+	remaining := u.session.FileSize - u.session.FileOffset
+	if int64(len(data)) > remaining {
+		writeError(w, r, "api.upload.upload_data.invalid_content_length", "Invalid content length.", http.StatusBadRequest)
+		return
+	}
+	u.data.Write(data)
+	u.session.FileOffset += int64(len(data))
+	if readErr != nil {
+		return
+	}
+
+	if u.session.FileOffset < u.session.FileSize {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	delete(s.uploads, u.session.Id)
+	writeJSON(w, http.StatusCreated, s.storeFile(userId, u.session.ChannelId, u.session.Filename, u.data.Bytes()))
+}
+
+// readableFile returns the file if userId can read its channel. s.mu must be held.
+func (s *Server) readableFile(w http.ResponseWriter, r *http.Request, userId string) *storedFile {
+	f, ok := s.files[r.PathValue("file_id")]
+	if !ok || f.info.DeleteAt != 0 || !s.canPost(userId, f.info.ChannelId) {
+		writeError(w, r, "app.file_info.get.app_error", "Unable to get the file info.", http.StatusNotFound)
+		return nil
+	}
+	return f
+}
+
+// getFile serves the content of a file, honouring Range requests.
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	f := s.readableFile(w, r, userId)
+	s.mu.Unlock()
+	if f == nil {
+		return
+	}
+	w.Header().Set("Content-Type", f.info.MimeType)
+	http.ServeContent(w, r, f.info.Name, time.UnixMilli(f.info.UpdateAt), bytes.NewReader(f.data))
+}
+
+func (s *Server) getFileInfo(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f := s.readableFile(w, r, userId); f != nil {
+		info := *f.info
+		writeJSON(w, http.StatusOK, &info)
+	}
+}
+
+// getFileImage serves thumbnails and previews, which are the image itself.
+func (s *Server) getFileImage(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	f := s.readableFile(w, r, userId)
+	s.mu.Unlock()
+	if f == nil {
+		return
+	}
+	if !f.info.HasPreviewImage {
+		writeError(w, r, "api.file.get_file_thumbnail.no_thumbnail.app_error", "File doesn't have a thumbnail image.", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", f.info.MimeType)
+	_, _ = w.Write(f.data)
+}
+
+func (s *Server) getFileLink(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f := s.readableFile(w, r, userId); f != nil {
+		writeJSON(w, http.StatusOK, map[string]string{"link": s.URL + "/files/" + f.info.Id + "/public?h=" + NewId()})
+	}
+}
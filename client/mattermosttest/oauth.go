@@ -0,0 +1,178 @@
+package mattermosttest
+
+import (
+	"net/http"
+	"slices"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// oauthGrant is what an authorization code or a refresh token was issued for.
+type oauthGrant struct {
+	appId, userId, redirectURI string
+}
+
+// AuthorizeOAuthApp approves the OAuth app for userId, as the user would on
+// the authorization page, and returns the code sent to redirectURI. It
+// returns "" when the app does not exist or redirectURI is not one of its
+// callback URLs.
+func (s *Server) AuthorizeOAuthApp(appId, userId, redirectURI string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.oauthApps[appId]
+	if !ok || !slices.Contains(app.CallbackUrls, redirectURI) {
+		return ""
+	}
+	code := NewId()
+	s.oauthCodes[code] = oauthGrant{appId: appId, userId: userId, redirectURI: redirectURI}
+	return code
+}
+
+func (s *Server) createOAuthApp(w http.ResponseWriter, r *http.Request, userId string) {
+	var app mattermost.OAuthApp
+	if !decodeBody(w, r, &app) {
+		return
+	}
+	if app.Name == "" || len(app.CallbackUrls) == 0 {
+		writeError(w, r, "model.oauth.is_valid.app_id.app_error", "Invalid OAuth app.", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app.Id = NewId()
+	app.CreatorId = userId
+	app.ClientSecret = NewId()
+	app.CreateAt = now()
+	app.UpdateAt = app.CreateAt
+	s.oauthApps[app.Id] = &app
+	res := app
+	writeJSON(w, http.StatusCreated, &res)
+}
+
+// ownOAuthApp returns the app if userId created it or is a system admin.
+// s.mu must be held.
+func (s *Server) ownOAuthApp(w http.ResponseWriter, r *http.Request, userId string) *mattermost.OAuthApp {
+	app, ok := s.oauthApps[r.PathValue("app_id")]
+	if !ok {
+		writeError(w, r, "app.oauth.get_app.find.app_error", "Unable to find the OAuth app.", http.StatusNotFound)
+		return nil
+	}
+	if app.CreatorId != userId && !isSystemAdmin(s.users[userId]) {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return nil
+	}
+	return app
+}
+
+func (s *Server) getOAuthApps(w http.ResponseWriter, r *http.Request, userId string) {
+	page, perPage := paging(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var apps []*mattermost.OAuthApp
+	for _, app := range s.oauthApps {
+		if app.CreatorId == userId || isSystemAdmin(s.users[userId]) {
+			apps = append(apps, app)
+		}
+	}
+	slices.SortFunc(apps, func(a, b *mattermost.OAuthApp) int { return int(a.CreateAt - b.CreateAt) })
+
+	res := []*mattermost.OAuthApp{}
+	for _, app := range apps[min(page*perPage, len(apps)):min((page+1)*perPage, len(apps))] {
+		c := *app
+		res = append(res, &c)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getOAuthApp(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if app := s.ownOAuthApp(w, r, userId); app != nil {
+		res := *app
+		writeJSON(w, http.StatusOK, &res)
+	}
+}
+
+func (s *Server) regenerateOAuthAppSecret(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if app := s.ownOAuthApp(w, r, userId); app != nil {
+		app.ClientSecret = NewId()
+		app.UpdateAt = now()
+		res := *app
+		writeJSON(w, http.StatusOK, &res)
+	}
+}
+
+// deleteOAuthApp deletes the app and the sessions and refresh tokens issued
+// to it.
+func (s *Server) deleteOAuthApp(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.ownOAuthApp(w, r, userId)
+	if app == nil {
+		return
+	}
+	delete(s.oauthApps, app.Id)
+	for token, appId := range s.oauthSessions {
+		if appId == app.Id {
+			delete(s.oauthSessions, token)
+			delete(s.sessions, token)
+		}
+	}
+	for token, grant := range s.refreshTokens {
+		if grant.appId == app.Id {
+			delete(s.refreshTokens, token)
+		}
+	}
+	writeStatusOK(w)
+}
+
+// accessToken implements the token endpoint of the authorization-code and
+// refresh-token flows. Codes and refresh tokens can be used once.
+func (s *Server) accessToken(w http.ResponseWriter, r *http.Request, _ string) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, "api.oauth.get_access_token.bad_request.app_error", "Bad request.", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.oauthApps[r.PostForm.Get("client_id")]
+	if !ok || app.ClientSecret != r.PostForm.Get("client_secret") {
+		writeError(w, r, "api.oauth.get_access_token.credentials.app_error", "Invalid client credentials.", http.StatusForbidden)
+		return
+	}
+
+	var grant oauthGrant
+	switch r.PostForm.Get("grant_type") {
+	case mattermost.AccessTokenGrantType:
+		code := r.PostForm.Get("code")
+		grant, ok = s.oauthCodes[code]
+		delete(s.oauthCodes, code)
+	case mattermost.RefreshTokenGrantType:
+		token := r.PostForm.Get("refresh_token")
+		grant, ok = s.refreshTokens[token]
+		delete(s.refreshTokens, token)
+	default:
+		writeError(w, r, "api.oauth.get_access_token.bad_grant.app_error", "invalid_request: Bad grant_type.", http.StatusBadRequest)
+		return
+	}
+	if !ok || grant.appId != app.Id || grant.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeError(w, r, "api.oauth.get_access_token.expired_code.app_error", "invalid_grant: Invalid or expired authorization code.", http.StatusBadRequest)
+		return
+	}
+
+	res := &mattermost.AccessResponse{
+		AccessToken:      NewId(),
+		TokenType:        mattermost.AccessTokenType,
+		ExpiresInSeconds: 3600,
+		RefreshToken:     NewId(),
+	}
+	s.sessions[res.AccessToken] = grant.userId
+	s.oauthSessions[res.AccessToken] = app.Id
+	s.refreshTokens[res.RefreshToken] = grant
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,165 @@
+package mattermosttest
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// searchTerms is a parsed SearchParameter.Terms. Words are matched against
+// the words of a message and phrases against the message itself, ignoring
+// case; the filters must all hold.
+type searchTerms struct {
+	words, phrases, excluded []string
+	from, notFrom            []string
+	in, notIn                []string
+	before, after, on        string
+}
+
+// parseSearchTerms splits terms like the server: a quoted phrase, optionally
+// preceded by -, is one term and every other term ends at a space.
+func parseSearchTerms(terms string) *searchTerms {
+	st := &searchTerms{}
+	for terms = strings.TrimSpace(terms); terms != ""; terms = strings.TrimSpace(terms) {
+		exclude := strings.HasPrefix(terms, `-"`)
+		if exclude || strings.HasPrefix(terms, `"`) {
+			rest := strings.TrimPrefix(strings.TrimPrefix(terms, "-"), `"`)
+			phrase, after, _ := strings.Cut(rest, `"`)
+			terms = after
+			if exclude {
+				st.excluded = append(st.excluded, strings.ToLower(phrase))
+			} else {
+				st.phrases = append(st.phrases, strings.ToLower(phrase))
+			}
+			continue
+		}
+
+		term, after, _ := strings.Cut(terms, " ")
+		terms = after
+		switch name, value, _ := strings.Cut(term, ":"); {
+		case value != "" && name == "from":
+			st.from = append(st.from, value)
+		case value != "" && name == "-from":
+			st.notFrom = append(st.notFrom, value)
+		case value != "" && name == "in":
+			st.in = append(st.in, value)
+		case value != "" && name == "-in":
+			st.notIn = append(st.notIn, value)
+		case value != "" && name == "before":
+			st.before = value
+		case value != "" && name == "after":
+			st.after = value
+		case value != "" && name == "on":
+			st.on = value
+		case strings.HasPrefix(term, "-") && len(term) > 1:
+			st.excluded = append(st.excluded, strings.ToLower(term[1:]))
+		default:
+			st.words = append(st.words, strings.ToLower(term))
+		}
+	}
+	return st
+}
+
+// match returns the terms p matches, and whether it is a result.
+func (st *searchTerms) match(p *mattermost.Post, username, channelName string, zone *time.Location, isOr bool) ([]string, bool) {
+	if (len(st.from) > 0 && !containsFold(st.from, username)) || containsFold(st.notFrom, username) {
+		return nil, false
+	}
+	if (len(st.in) > 0 && !containsFold(st.in, channelName)) || containsFold(st.notIn, channelName) {
+		return nil, false
+	}
+	day := time.UnixMilli(p.CreateAt).In(zone).Format("2006-01-02")
+	if (st.before != "" && day >= st.before) || (st.after != "" && day <= st.after) || (st.on != "" && day != st.on) {
+		return nil, false
+	}
+
+	message := strings.ToLower(p.Message)
+	words := strings.FieldsFunc(message, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("#-_.", r)
+	})
+	for _, ex := range st.excluded {
+		if strings.Contains(ex, " ") && strings.Contains(message, ex) || slices.Contains(words, ex) {
+			return nil, false
+		}
+	}
+
+	var matched []string
+	for _, w := range st.words {
+		if slices.Contains(words, w) {
+			matched = append(matched, w)
+		}
+	}
+	for _, ph := range st.phrases {
+		if strings.Contains(message, ph) {
+			matched = append(matched, ph)
+		}
+	}
+	terms := len(st.words) + len(st.phrases)
+	if terms > 0 && (len(matched) == 0 || !isOr && len(matched) < terms) {
+		return nil, false
+	}
+	return matched, true
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
+
+// searchPosts searches the posts of the channels of a team the user is a
+// member of, newest first.
+func (s *Server) searchPosts(w http.ResponseWriter, r *http.Request, userId string) {
+	var params mattermost.SearchParameter
+	if !decodeBody(w, r, &params) {
+		return
+	}
+	st := parseSearchTerms(params.Terms)
+	zone := time.FixedZone("", params.TimeZoneOffset)
+	perPage := params.PerPage
+	if perPage <= 0 {
+		perPage = 60
+	}
+	perPage = min(perPage, maxPerPage)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	teamId := r.PathValue("team_id")
+	if _, ok := s.teams[teamId]; !ok {
+		writeError(w, r, "app.team.get.find.app_error", "Unable to find the existing team.", http.StatusNotFound)
+		return
+	}
+
+	var posts []*mattermost.Post
+	matches := mattermost.PostSearchMatches{}
+	for _, p := range s.posts {
+		ch := s.channels[p.ChannelId]
+		if p.DeleteAt != 0 || ch == nil || s.members[ch.Id][userId] == nil {
+			continue
+		}
+		if (ch.TeamId != "" && ch.TeamId != teamId) || (ch.DeleteAt != 0 && !params.IncludeDeletedChannels) {
+			continue
+		}
+		var username string
+		if u := s.users[p.UserId]; u != nil {
+			username = u.Username
+		}
+		if matched, ok := st.match(p, username, ch.Name, zone, params.IsOrSearch); ok {
+			posts = append(posts, p)
+			matches[p.Id] = matched
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].CreateAt > posts[j].CreateAt })
+
+	res := mattermost.PostSearchResults{PostList: mattermost.NewPostList(), Matches: mattermost.PostSearchMatches{}}
+	start := min(max(params.Page, 0)*perPage, len(posts))
+	for _, p := range posts[start:min(start+perPage, len(posts))] {
+		res.Order = append(res.Order, p.Id)
+		res.Posts[p.Id] = clonePost(p)
+		res.Matches[p.Id] = matches[p.Id]
+	}
+	writeJSON(w, http.StatusOK, &res)
+}
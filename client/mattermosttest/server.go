@@ -0,0 +1,1003 @@
+// Package mattermosttest provides an in-memory Mattermost server for testing
+// code built on the mattermost client package without a live server.
+package mattermosttest
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+var idEncoding = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").WithPadding(base32.NoPadding)
+
+// NewId returns a random 26 character identifier in the format used by Mattermost.
+func NewId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return idEncoding.EncodeToString(b)
+}
+
+// Server is an httptest server implementing the subset of /api/v4 used by the
+// client package, backed by in-memory state. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	users     map[string]*mattermost.User
+	passwords map[string]string
+	sessions  map[string]string // token -> user id
+	tokens    map[string]*mattermost.UserAccessToken
+	teams     map[string]*mattermost.Team
+	channels  map[string]*mattermost.Channel
+	members   map[string]map[string]*mattermost.ChannelMember // channel id -> user id -> member
+	posts     map[string]*mattermost.Post
+	reactions map[string][]*mattermost.Reaction // post id -> reactions
+	files     map[string]*storedFile
+	uploads   map[string]*upload
+	following map[string]bool // user id + thread id
+	sockets   map[*socket]bool
+	lastPost  int64 // Create time of the newest post, kept increasing so that posts sort stably
+
+	oauthApps     map[string]*mattermost.OAuthApp
+	oauthCodes    map[string]oauthGrant
+	oauthSessions map[string]string // access token -> app id
+	refreshTokens map[string]oauthGrant
+
+	rateLimit  int // Requests allowed per second and session, 0 disables rate limiting
+	rateWindow map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewServer starts a server with no data. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		users:      map[string]*mattermost.User{},
+		passwords:  map[string]string{},
+		sessions:   map[string]string{},
+		tokens:     map[string]*mattermost.UserAccessToken{},
+		teams:      map[string]*mattermost.Team{},
+		channels:   map[string]*mattermost.Channel{},
+		members:    map[string]map[string]*mattermost.ChannelMember{},
+		posts:      map[string]*mattermost.Post{},
+		reactions:  map[string][]*mattermost.Reaction{},
+		files:      map[string]*storedFile{},
+		uploads:    map[string]*upload{},
+		following:  map[string]bool{},
+		sockets:    map[*socket]bool{},
+		rateWindow: map[string]*rateWindow{},
+
+		oauthApps:     map[string]*mattermost.OAuthApp{},
+		oauthCodes:    map[string]oauthGrant{},
+		oauthSessions: map[string]string{},
+		refreshTokens: map[string]oauthGrant{},
+	}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// Client returns a client for the server authenticated as userId, or an
+// anonymous client when userId is empty.
+func (s *Server) Client(userId string) *mattermost.Client4 {
+	c := mattermost.NewAPIv4Client(s.URL)
+	if userId != "" {
+		c.SetToken(s.CreateSession(userId))
+	}
+	return c
+}
+
+// SetRateLimit limits every session to perSecond requests per second. Zero
+// disables rate limiting.
+func (s *Server) SetRateLimit(perSecond int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = perSecond
+	s.rateWindow = map[string]*rateWindow{}
+}
+
+// AddUser stores a copy of u with a new id and returns it. The user can log in
+// with its username or email and password.
+func (s *Server) AddUser(u *mattermost.User, password string) *mattermost.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nu := *u
+	nu.Id = NewId()
+	nu.CreateAt = now()
+	nu.UpdateAt = nu.CreateAt
+	nu.Password = ""
+	s.users[nu.Id] = &nu
+	s.passwords[nu.Id] = password
+	res := nu
+	return &res
+}
+
+// AddTeam stores a copy of t with a new id and returns it.
+func (s *Server) AddTeam(t *mattermost.Team) *mattermost.Team {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nt := *t
+	nt.Id = NewId()
+	nt.CreateAt = now()
+	nt.UpdateAt = nt.CreateAt
+	s.teams[nt.Id] = &nt
+	res := nt
+	return &res
+}
+
+// AddChannel stores a copy of ch with a new id and returns it.
+func (s *Server) AddChannel(ch *mattermost.Channel) *mattermost.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addChannel(ch)
+}
+
+func (s *Server) addChannel(ch *mattermost.Channel) *mattermost.Channel {
+	nc := *ch
+	nc.Id = NewId()
+	nc.CreateAt = now()
+	nc.UpdateAt = nc.CreateAt
+	s.channels[nc.Id] = &nc
+	s.members[nc.Id] = map[string]*mattermost.ChannelMember{}
+	res := nc
+	return &res
+}
+
+// AddChannelMember adds a user to a channel.
+func (s *Server) AddChannelMember(channelId, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addChannelMember(channelId, userId)
+}
+
+func (s *Server) addChannelMember(channelId, userId string) {
+	if s.members[channelId] == nil {
+		s.members[channelId] = map[string]*mattermost.ChannelMember{}
+	}
+	s.members[channelId][userId] = &mattermost.ChannelMember{
+		ChannelId:    channelId,
+		UserId:       userId,
+		Roles:        "channel_user",
+		NotifyProps:  mattermost.StringMap{},
+		LastUpdateAt: now(),
+		SchemeUser:   true,
+	}
+}
+
+// CreateSession opens a session for userId and returns its token.
+func (s *Server) CreateSession(userId string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := NewId()
+	s.sessions[token] = userId
+	return token
+}
+
+// RevokeSession invalidates a session token, as if it had expired.
+func (s *Server) RevokeSession(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Posts returns copies of the posts of a channel, oldest first.
+func (s *Server) Posts(channelId string) []*mattermost.Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*mattermost.Post
+	for _, p := range s.posts {
+		if p.ChannelId == channelId {
+			list = append(list, clonePost(p))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreateAt < list[j].CreateAt })
+	return list
+}
+
+// Reactions returns copies of the reactions to a post.
+func (s *Server) Reactions(postId string) []*mattermost.Reaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneReactions(s.reactions[postId])
+}
+
+// IsFollowing reports whether a user follows a thread.
+func (s *Server) IsFollowing(userId, threadId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.following[userId+threadId]
+}
+
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+func clonePost(p *mattermost.Post) *mattermost.Post {
+	b, _ := json.Marshal(p)
+	var np mattermost.Post
+	_ = json.Unmarshal(b, &np)
+	return &np
+}
+
+func cloneReactions(list []*mattermost.Reaction) []*mattermost.Reaction {
+	res := make([]*mattermost.Reaction, 0, len(list))
+	for _, r := range list {
+		nr := *r
+		res = append(res, &nr)
+	}
+	return res
+}
+
+func isSystemAdmin(u *mattermost.User) bool {
+	return u != nil && slices.Contains(strings.Fields(u.Roles), "system_admin")
+}
+
+func sanitizeUser(u *mattermost.User) *mattermost.User {
+	nu := *u
+	nu.Password = ""
+	nu.MfaSecret = ""
+	return &nu
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, userId string)
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	const api = mattermost.APIURLSuffix
+
+	mux.HandleFunc("GET "+api+"/websocket", s.authed(s.connectWebSocket))
+
+	mux.HandleFunc("POST "+api+"/users/login", s.withRequestId(s.login))
+	mux.HandleFunc("POST "+api+"/users/logout", s.authed(s.logout))
+	mux.HandleFunc("GET "+api+"/users/me", s.authed(s.getMe))
+	mux.HandleFunc("GET "+api+"/users/{user_id}", s.authed(s.getUser))
+	mux.HandleFunc("GET "+api+"/users/username/{username}", s.authed(s.getUserByUsername))
+	mux.HandleFunc("GET "+api+"/users/email/{email}", s.authed(s.getUserByEmail))
+	mux.HandleFunc("POST "+api+"/users/{user_id}/tokens", s.authed(s.createUserAccessToken))
+	mux.HandleFunc("POST "+api+"/users/tokens/revoke", s.authed(s.revokeUserAccessToken))
+	mux.HandleFunc("PUT "+api+"/users/{user_id}/teams/{team_id}/threads/{thread_id}/following", s.authed(s.followThread))
+	mux.HandleFunc("DELETE "+api+"/users/{user_id}/teams/{team_id}/threads/{thread_id}/following", s.authed(s.unfollowThread))
+
+	mux.HandleFunc("GET "+api+"/teams/name/{name}", s.authed(s.getTeamByName))
+	mux.HandleFunc("POST "+api+"/teams/{team_id}/posts/search", s.authed(s.searchPosts))
+
+	mux.HandleFunc("POST "+api+"/channels/direct", s.authed(s.createDirectChannel))
+	mux.HandleFunc("GET "+api+"/channels/{channel_id}", s.authed(s.getChannel))
+	mux.HandleFunc("GET "+api+"/channels/{channel_id}/members", s.authed(s.getChannelMembers))
+	mux.HandleFunc("GET "+api+"/channels/{channel_id}/members/{user_id}", s.authed(s.getChannelMember))
+	mux.HandleFunc("GET "+api+"/channels/{channel_id}/posts", s.authed(s.getPostsForChannel))
+
+	mux.HandleFunc("POST "+api+"/posts", s.authed(s.createPost))
+	mux.HandleFunc("POST "+api+"/posts/ids", s.authed(s.getPostsByIds))
+	mux.HandleFunc("GET "+api+"/posts/{post_id}", s.authed(s.getPost))
+	mux.HandleFunc("PUT "+api+"/posts/{post_id}", s.authed(s.updatePost))
+	mux.HandleFunc("PUT "+api+"/posts/{post_id}/patch", s.authed(s.patchPost))
+	mux.HandleFunc("DELETE "+api+"/posts/{post_id}", s.authed(s.deletePost))
+	mux.HandleFunc("GET "+api+"/posts/{post_id}/thread", s.authed(s.getPostThread))
+	mux.HandleFunc("GET "+api+"/posts/{post_id}/reactions", s.authed(s.getReactions))
+	mux.HandleFunc("POST "+api+"/posts/ids/reactions", s.authed(s.getReactionsForPosts))
+
+	mux.HandleFunc("POST "+api+"/files", s.authed(s.uploadFile))
+	mux.HandleFunc("GET "+api+"/files/{file_id}", s.authed(s.getFile))
+	mux.HandleFunc("GET "+api+"/files/{file_id}/info", s.authed(s.getFileInfo))
+	mux.HandleFunc("GET "+api+"/files/{file_id}/thumbnail", s.authed(s.getFileImage))
+	mux.HandleFunc("GET "+api+"/files/{file_id}/preview", s.authed(s.getFileImage))
+	mux.HandleFunc("GET "+api+"/files/{file_id}/link", s.authed(s.getFileLink))
+	mux.HandleFunc("POST "+api+"/uploads", s.authed(s.createUpload))
+	mux.HandleFunc("GET "+api+"/uploads/{upload_id}", s.authed(s.getUpload))
+	mux.HandleFunc("POST "+api+"/uploads/{upload_id}", s.authed(s.uploadData))
+
+	mux.HandleFunc("POST "+api+"/reactions", s.authed(s.saveReaction))
+	mux.HandleFunc("DELETE "+api+"/users/{user_id}/posts/{post_id}/reactions/{emoji_name}", s.authed(s.deleteReaction))
+
+	mux.HandleFunc("POST "+api+"/oauth/apps", s.authed(s.createOAuthApp))
+	mux.HandleFunc("GET "+api+"/oauth/apps", s.authed(s.getOAuthApps))
+	mux.HandleFunc("GET "+api+"/oauth/apps/{app_id}", s.authed(s.getOAuthApp))
+	mux.HandleFunc("POST "+api+"/oauth/apps/{app_id}/regen_secret", s.authed(s.regenerateOAuthAppSecret))
+	mux.HandleFunc("DELETE "+api+"/oauth/apps/{app_id}", s.authed(s.deleteOAuthApp))
+	mux.HandleFunc("POST "+mattermost.OAuthAccessTokenURLSuffix, s.withRequestId(s.accessToken))
+
+	mux.HandleFunc("/", s.withRequestId(func(w http.ResponseWriter, r *http.Request, _ string) {
+		writeError(w, r, "api.context.404.app_error", "Sorry, we could not find the page.", http.StatusNotFound)
+	}))
+	return mux
+}
+
+func (s *Server) withRequestId(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(mattermost.HeaderRequestId, NewId())
+		w.Header().Set(mattermost.HeaderVersionId, "fake")
+		h(w, r, "")
+	}
+}
+
+// authed resolves the session from the Authorization header and applies the
+// rate limit before calling h with the session's user id.
+func (s *Server) authed(h handlerFunc) http.HandlerFunc {
+	return s.withRequestId(func(w http.ResponseWriter, r *http.Request, _ string) {
+		token := bearerToken(r)
+
+		s.mu.Lock()
+		userId, ok := s.sessions[token]
+		allowed := s.allow(w, token)
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, r, "api.context.session_expired.app_error", "Invalid or expired session, please login again.", http.StatusUnauthorized)
+			return
+		}
+		if !allowed {
+			writeError(w, r, "api.context.rate_limit.app_error", "Too many requests.", http.StatusTooManyRequests)
+			return
+		}
+		h(w, r, userId)
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	prefix := mattermost.HeaderBearer + " "
+	auth := r.Header.Get(mattermost.HeaderAuth)
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+	return auth[len(prefix):]
+}
+
+// allow counts the request against the session's window and sets the
+// X-RateLimit-* headers. s.mu must be held.
+func (s *Server) allow(w http.ResponseWriter, key string) bool {
+	if s.rateLimit <= 0 {
+		return true
+	}
+	t := time.Now()
+	win := s.rateWindow[key]
+	if win == nil || t.Sub(win.start) >= time.Second {
+		win = &rateWindow{start: t}
+		s.rateWindow[key] = win
+	}
+	win.count++
+	remaining := max(s.rateLimit-win.count, 0)
+	reset := int(time.Until(win.start.Add(time.Second)).Seconds() + 0.999)
+
+	w.Header().Set(mattermost.HeaderRateLimitLimit, strconv.Itoa(s.rateLimit))
+	w.Header().Set(mattermost.HeaderRateLimitRemaining, strconv.Itoa(remaining))
+	w.Header().Set(mattermost.HeaderRateLimitReset, strconv.Itoa(reset))
+	if win.count > s.rateLimit {
+		w.Header().Set(mattermost.HeaderRetryAfter, strconv.Itoa(reset))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, id, message string, status int) {
+	appErr := mattermost.NewAppError(r.Method+" "+r.URL.Path, id, nil, "", status)
+	appErr.Message = message
+	appErr.RequestId = w.Header().Get(mattermost.HeaderRequestId)
+	writeJSON(w, status, appErr)
+}
+
+func writeStatusOK(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]string{mattermost.STATUS: mattermost.StatusOk})
+}
+
+// writeCacheable writes v with an ETag, or 304 when the client already has it.
+func writeCacheable(w http.ResponseWriter, r *http.Request, etag string, v any) {
+	w.Header().Set(mattermost.HeaderEtagServer, etag)
+	if r.Header.Get(mattermost.HeaderEtagClient) == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func etag(parts ...any) string {
+	strs := make([]string, len(parts))
+	for i, p := range parts {
+		strs[i] = fmt.Sprint(p)
+	}
+	return strings.Join(strs, ".")
+}
+
+// maxPerPage is the largest page the server returns, whatever the client asks for.
+const maxPerPage = 200
+
+// paging returns the page and per_page query parameters of r, defaulting to
+// 60 items per page and capped at maxPerPage like the real server.
+func paging(r *http.Request) (page, perPage int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 60
+	}
+	return max(page, 0), min(perPage, maxPerPage)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, r, "api.context.invalid_body_param.app_error", "Invalid or missing body in request.", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request, _ string) {
+	var m map[string]string
+	if !decodeBody(w, r, &m) {
+		return
+	}
+
+	s.mu.Lock()
+	var user *mattermost.User
+	for _, u := range s.users {
+		if u.Username == m["login_id"] || (u.Email != "" && u.Email == m["login_id"]) {
+			user = u
+			break
+		}
+	}
+	if user == nil || s.passwords[user.Id] != m["password"] || (user.MfaActive && m["token"] == "") {
+		s.mu.Unlock()
+		writeError(w, r, "api.user.login.invalid_credentials_email_username", "Enter a valid email or username and/or password.", http.StatusUnauthorized)
+		return
+	}
+	token := NewId()
+	s.sessions[token] = user.Id
+	res := sanitizeUser(user)
+	s.mu.Unlock()
+
+	w.Header().Set(mattermost.HeaderToken, token)
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	delete(s.sessions, bearerToken(r))
+	s.mu.Unlock()
+	writeStatusOK(w)
+}
+
+func (s *Server) writeUser(w http.ResponseWriter, r *http.Request, match func(*mattermost.User) bool) {
+	s.mu.Lock()
+	var res *mattermost.User
+	for _, u := range s.users {
+		if match(u) {
+			res = sanitizeUser(u)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if res == nil {
+		writeError(w, r, "app.user.missing_account.const", "Unable to find the user.", http.StatusNotFound)
+		return
+	}
+	writeCacheable(w, r, etag(res.Id, res.UpdateAt), res)
+}
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request, userId string) {
+	s.writeUser(w, r, func(u *mattermost.User) bool { return u.Id == userId })
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request, _ string) {
+	id := r.PathValue("user_id")
+	s.writeUser(w, r, func(u *mattermost.User) bool { return u.Id == id })
+}
+
+func (s *Server) getUserByUsername(w http.ResponseWriter, r *http.Request, _ string) {
+	name := r.PathValue("username")
+	s.writeUser(w, r, func(u *mattermost.User) bool { return u.Username == name })
+}
+
+func (s *Server) getUserByEmail(w http.ResponseWriter, r *http.Request, _ string) {
+	email := r.PathValue("email")
+	s.writeUser(w, r, func(u *mattermost.User) bool { return u.Email == email })
+}
+
+func (s *Server) setFollowing(w http.ResponseWriter, r *http.Request, userId string, state bool) {
+	target := r.PathValue("user_id")
+	if target == mattermost.Me {
+		target = userId
+	}
+	s.mu.Lock()
+	if state {
+		s.following[target+r.PathValue("thread_id")] = true
+	} else {
+		delete(s.following, target+r.PathValue("thread_id"))
+	}
+	s.mu.Unlock()
+	writeStatusOK(w)
+}
+
+func (s *Server) followThread(w http.ResponseWriter, r *http.Request, userId string) {
+	s.setFollowing(w, r, userId, true)
+}
+
+func (s *Server) unfollowThread(w http.ResponseWriter, r *http.Request, userId string) {
+	s.setFollowing(w, r, userId, false)
+}
+
+func (s *Server) getTeamByName(w http.ResponseWriter, r *http.Request, _ string) {
+	name := r.PathValue("name")
+	s.mu.Lock()
+	var res *mattermost.Team
+	for _, t := range s.teams {
+		if t.Name == name {
+			nt := *t
+			res = &nt
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if res == nil {
+		writeError(w, r, "app.team.get_by_name.missing.app_error", "Unable to find the existing team.", http.StatusNotFound)
+		return
+	}
+	writeCacheable(w, r, etag(res.Id, res.UpdateAt), res)
+}
+
+func (s *Server) createDirectChannel(w http.ResponseWriter, r *http.Request, _ string) {
+	var ids []string
+	if !decodeBody(w, r, &ids) {
+		return
+	}
+	if len(ids) != 2 {
+		writeError(w, r, "api.context.invalid_body_param.app_error", "Invalid or missing user_ids in request body.", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if s.users[id] == nil {
+			writeError(w, r, "app.user.missing_account.const", "Unable to find the user.", http.StatusNotFound)
+			return
+		}
+	}
+
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	name := sorted[0] + "__" + sorted[1]
+	for _, ch := range s.channels {
+		if ch.Type == mattermost.ChannelTypeDirect && ch.Name == name {
+			writeJSON(w, http.StatusCreated, ch)
+			return
+		}
+	}
+
+	ch := s.addChannel(&mattermost.Channel{Type: mattermost.ChannelTypeDirect, Name: name})
+	for _, id := range sorted {
+		s.addChannelMember(ch.Id, id)
+	}
+	writeJSON(w, http.StatusCreated, ch)
+}
+
+func (s *Server) getChannel(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	ch, ok := s.channels[r.PathValue("channel_id")]
+	var res mattermost.Channel
+	if ok {
+		res = *ch
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, "app.channel.get.existing.app_error", "Unable to find the existing channel.", http.StatusNotFound)
+		return
+	}
+	writeCacheable(w, r, etag(res.Id, res.UpdateAt, res.LastPostAt), &res)
+}
+
+func (s *Server) getChannelMembers(w http.ResponseWriter, r *http.Request, _ string) {
+	page, perPage := paging(r)
+
+	s.mu.Lock()
+	members, ok := s.members[r.PathValue("channel_id")]
+	list := mattermost.ChannelMembers{}
+	for _, m := range members {
+		list = append(list, *m)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, "app.channel.get.existing.app_error", "Unable to find the existing channel.", http.StatusNotFound)
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UserId < list[j].UserId })
+	start := min(page*perPage, len(list))
+	end := min(start+perPage, len(list))
+	writeJSON(w, http.StatusOK, list[start:end])
+}
+
+func (s *Server) getChannelMember(w http.ResponseWriter, r *http.Request, _ string) {
+	s.mu.Lock()
+	m, ok := s.members[r.PathValue("channel_id")][r.PathValue("user_id")]
+	var res mattermost.ChannelMember
+	if ok {
+		res = *m
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, "app.channel.get_member.missing.app_error", "No channel member found for that user ID and channel ID.", http.StatusNotFound)
+		return
+	}
+	writeCacheable(w, r, etag(res.ChannelId, res.UserId, res.LastUpdateAt), &res)
+}
+
+func (s *Server) createPost(w http.ResponseWriter, r *http.Request, userId string) {
+	var p mattermost.Post
+	if !decodeBody(w, r, &p) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.channels[p.ChannelId]
+	if !ok {
+		writeError(w, r, "api.context.invalid_param.app_error", "Invalid channel_id parameter.", http.StatusBadRequest)
+		return
+	}
+	if s.members[ch.Id][userId] == nil {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+	if p.RootId != "" && s.posts[p.RootId] == nil {
+		writeError(w, r, "api.post.create_post.root_id.app_error", "Invalid RootId parameter.", http.StatusBadRequest)
+		return
+	}
+
+	np := clonePost(&p)
+	np.Id = NewId()
+	np.UserId = userId
+	np.CreateAt = max(now(), s.lastPost+1)
+	s.lastPost = np.CreateAt
+	np.UpdateAt = np.CreateAt
+	s.posts[np.Id] = np
+	for _, id := range np.FileIds {
+		if f, ok := s.files[id]; ok && f.info.CreatorId == userId {
+			f.info.PostId = np.Id
+		}
+	}
+	if np.RootId != "" {
+		s.posts[np.RootId].ReplyCount++
+	}
+	ch.LastPostAt = np.CreateAt
+	ch.TotalMsgCount++
+	s.publishPosted(np)
+
+	writeJSON(w, http.StatusCreated, np)
+}
+
+func (s *Server) updatePost(w http.ResponseWriter, r *http.Request, userId string) {
+	var p mattermost.Post
+	if !decodeBody(w, r, &p) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.posts[r.PathValue("post_id")]
+	if !ok || existing.DeleteAt != 0 {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	if existing.UserId != userId {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+
+	existing.Message = p.Message
+	existing.Properties = p.Properties
+	existing.IsPinned = p.IsPinned
+	existing.EditAt = now()
+	existing.UpdateAt = existing.EditAt
+
+	writeJSON(w, http.StatusOK, existing)
+}
+
+// readablePost returns the post if it exists and userId can read it. s.mu must be held.
+func (s *Server) readablePost(id, userId string) *mattermost.Post {
+	p, ok := s.posts[id]
+	if !ok || p.DeleteAt != 0 || s.members[p.ChannelId][userId] == nil {
+		return nil
+	}
+	return p
+}
+
+func (s *Server) getPost(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	p := s.readablePost(r.PathValue("post_id"), userId)
+	var res *mattermost.Post
+	if p != nil {
+		res = clonePost(p)
+	}
+	s.mu.Unlock()
+
+	if res == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	writeCacheable(w, r, etag(res.Id, res.UpdateAt), res)
+}
+
+func (s *Server) getPostsByIds(w http.ResponseWriter, r *http.Request, userId string) {
+	var ids []string
+	if !decodeBody(w, r, &ids) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []*mattermost.Post{}
+	for _, id := range ids {
+		if p := s.readablePost(id, userId); p != nil {
+			list = append(list, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) patchPost(w http.ResponseWriter, r *http.Request, userId string) {
+	var patch mattermost.PostPatch
+	if !decodeBody(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := s.readablePost(r.PathValue("post_id"), userId)
+	if existing == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	if existing.UserId != userId {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+
+	if patch.IsPinned != nil {
+		existing.IsPinned = *patch.IsPinned
+	}
+	if patch.Message != nil {
+		existing.Message = *patch.Message
+		existing.EditAt = now()
+	}
+	if patch.Props != nil {
+		existing.Properties = *patch.Props
+	}
+	if patch.FileIds != nil {
+		existing.FileIds = *patch.FileIds
+	}
+	if patch.HasReactions != nil {
+		existing.HasReactions = *patch.HasReactions
+	}
+	existing.UpdateAt = now()
+
+	writeJSON(w, http.StatusOK, existing)
+}
+
+func (s *Server) deletePost(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.readablePost(r.PathValue("post_id"), userId)
+	if p == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	if p.UserId != userId {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+
+	deleteAt := now()
+	for _, other := range s.posts {
+		if other.Id == p.Id || (p.RootId == "" && other.RootId == p.Id) {
+			other.DeleteAt = deleteAt
+			other.UpdateAt = deleteAt
+		}
+	}
+	if p.RootId != "" && s.posts[p.RootId] != nil {
+		s.posts[p.RootId].ReplyCount--
+	}
+	writeStatusOK(w)
+}
+
+func (s *Server) getPostThread(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.readablePost(r.PathValue("post_id"), userId)
+	if p == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+
+	rootId := p.Id
+	if p.RootId != "" {
+		rootId = p.RootId
+	}
+	list := mattermost.NewPostList()
+	for _, other := range s.posts {
+		if other.DeleteAt == 0 && (other.Id == rootId || other.RootId == rootId) {
+			list.Posts[other.Id] = other
+			list.Order = append(list.Order, other.Id)
+		}
+	}
+	list.SortByCreateAt()
+	writeJSON(w, http.StatusOK, list)
+}
+
+// getPostsForChannel pages through the posts of a channel newest first. Posts
+// created in the same millisecond are ordered by id so that cursors are exact.
+func (s *Server) getPostsForChannel(w http.ResponseWriter, r *http.Request, userId string) {
+	q := r.URL.Query()
+	page, perPage := paging(r)
+	since, _ := strconv.ParseInt(q.Get("since"), 10, 64)
+	includeDeleted := q.Get("include_deleted") == "true"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channelId := r.PathValue("channel_id")
+	if s.members[channelId][userId] == nil || (includeDeleted && !isSystemAdmin(s.users[userId])) {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+
+	var posts []*mattermost.Post
+	for _, p := range s.posts {
+		if p.ChannelId != channelId || (p.DeleteAt != 0 && !includeDeleted) {
+			continue
+		}
+		if since > 0 && p.UpdateAt <= since {
+			continue
+		}
+		posts = append(posts, p)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if posts[i].CreateAt != posts[j].CreateAt {
+			return posts[i].CreateAt > posts[j].CreateAt
+		}
+		return posts[i].Id > posts[j].Id
+	})
+
+	if since == 0 {
+		if before := q.Get("before"); before != "" {
+			i := indexOfPost(posts, before)
+			posts = posts[min(i+1, len(posts)):]
+		} else if after := q.Get("after"); after != "" {
+			posts = posts[:max(indexOfPost(posts, after), 0)]
+			start := max(len(posts)-(page+1)*perPage, 0)
+			end := max(len(posts)-page*perPage, 0)
+			posts = posts[start:end]
+			page = 0
+		}
+		start := min(page*perPage, len(posts))
+		end := min(start+perPage, len(posts))
+		posts = posts[start:end]
+	}
+
+	list := mattermost.NewPostList()
+	for _, p := range posts {
+		list.Order = append(list.Order, p.Id)
+		list.Posts[p.Id] = clonePost(p)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func indexOfPost(posts []*mattermost.Post, id string) int {
+	for i, p := range posts {
+		if p.Id == id {
+			return i
+		}
+	}
+	return len(posts)
+}
+
+func (s *Server) saveReaction(w http.ResponseWriter, r *http.Request, userId string) {
+	var reaction mattermost.Reaction
+	if !decodeBody(w, r, &reaction) {
+		return
+	}
+	if reaction.UserId != userId {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+	if reaction.EmojiName == "" {
+		writeError(w, r, "model.reaction.is_valid.emoji_name.app_error", "Invalid emoji name.", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.readablePost(reaction.PostId, userId)
+	if p == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	for _, existing := range s.reactions[p.Id] {
+		if existing.UserId == userId && existing.EmojiName == reaction.EmojiName {
+			writeJSON(w, http.StatusOK, existing)
+			return
+		}
+	}
+
+	nr := reaction
+	nr.ChannelId = p.ChannelId
+	nr.CreateAt = now()
+	nr.UpdateAt = nr.CreateAt
+	s.reactions[p.Id] = append(s.reactions[p.Id], &nr)
+	p.HasReactions = true
+	p.UpdateAt = nr.CreateAt
+	writeJSON(w, http.StatusOK, &nr)
+}
+
+func (s *Server) deleteReaction(w http.ResponseWriter, r *http.Request, userId string) {
+	if r.PathValue("user_id") != userId {
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.readablePost(r.PathValue("post_id"), userId)
+	if p == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	list := s.reactions[p.Id][:0]
+	for _, existing := range s.reactions[p.Id] {
+		if existing.UserId != userId || existing.EmojiName != r.PathValue("emoji_name") {
+			list = append(list, existing)
+		}
+	}
+	s.reactions[p.Id] = list
+	p.HasReactions = len(list) > 0
+	p.UpdateAt = now()
+	writeStatusOK(w)
+}
+
+func (s *Server) getReactions(w http.ResponseWriter, r *http.Request, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.readablePost(r.PathValue("post_id"), userId)
+	if p == nil {
+		writeError(w, r, "app.post.get.app_error", "Unable to get the post.", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, cloneReactions(s.reactions[p.Id]))
+}
+
+func (s *Server) getReactionsForPosts(w http.ResponseWriter, r *http.Request, userId string) {
+	var ids []string
+	if !decodeBody(w, r, &ids) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := map[string][]*mattermost.Reaction{}
+	for _, id := range ids {
+		if p := s.readablePost(id, userId); p != nil {
+			res[id] = cloneReactions(s.reactions[id])
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
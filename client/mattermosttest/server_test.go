@@ -0,0 +1,279 @@
+package mattermosttest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestServerErrors(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	token := s.CreateSession(u.Id)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		id     string
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/nope", token: token, status: http.StatusNotFound, id: "api.context.404.app_error"},
+		{name: "missing session", method: http.MethodGet, path: "/users/me", status: http.StatusUnauthorized, id: "api.context.session_expired.app_error"},
+		{name: "unknown session", method: http.MethodGet, path: "/users/me", token: "expired", status: http.StatusUnauthorized, id: "api.context.session_expired.app_error"},
+		{name: "invalid body", method: http.MethodPost, path: "/posts", token: token, body: "{", status: http.StatusBadRequest, id: "api.context.invalid_body_param.app_error"},
+		{name: "wrong password", method: http.MethodPost, path: "/users/login", body: `{"login_id":"alice","password":"wrong"}`, status: http.StatusUnauthorized, id: "api.user.login.invalid_credentials_email_username"},
+		{name: "unknown user", method: http.MethodGet, path: "/users/" + mattermosttest.NewId(), token: token, status: http.StatusNotFound, id: "app.user.missing_account.const"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rq, _ := http.NewRequest(tc.method, s.URL+mattermost.APIURLSuffix+tc.path, strings.NewReader(tc.body))
+			if tc.token != "" {
+				rq.Header.Set(mattermost.HeaderAuth, mattermost.HeaderBearer+" "+tc.token)
+			}
+			rp, err := http.DefaultClient.Do(rq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rp.Body.Close()
+
+			var appErr mattermost.AppError
+			if err := json.NewDecoder(rp.Body).Decode(&appErr); err != nil {
+				t.Fatal(err)
+			}
+			if rp.StatusCode != tc.status || appErr.StatusCode != tc.status || appErr.Id != tc.id {
+				t.Fatalf("status %d, error %+v", rp.StatusCode, appErr)
+			}
+			if rid := rp.Header.Get(mattermost.HeaderRequestId); rid == "" || appErr.RequestId != rid {
+				t.Fatalf("request id %q, error request id %q", rid, appErr.RequestId)
+			}
+		})
+	}
+}
+
+func TestServerPosts(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	eve := s.AddUser(&mattermost.User{Username: "eve"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	s.AddChannelMember(ch.Id, bob.Id)
+	ac, bc, ec := s.Client(alice.Id), s.Client(bob.Id), s.Client(eve.Id)
+
+	root, _, err := ac.CreateSimpleMessagePost(ch.Id, "root", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replies []*mattermost.Post
+	for _, msg := range []string{"first", "second"} {
+		p, _, err := bc.CreateSimpleMessagePost(ch.Id, msg, root.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, p)
+	}
+
+	thread, _, err := ac.GetPostThread(replies[0].Id, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread.Order) != 3 || thread.Posts[root.Id].ReplyCount != 2 {
+		t.Fatalf("thread %v, reply count %d", thread.Order, thread.Posts[root.Id].ReplyCount)
+	}
+	for i := 1; i < len(thread.Order); i++ {
+		if thread.Posts[thread.Order[i-1]].CreateAt <= thread.Posts[thread.Order[i]].CreateAt {
+			t.Fatalf("thread not sorted newest first: %v", thread.Order)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		call   func() error
+	}{
+		{name: "post outside the channel", status: http.StatusForbidden, call: func() error {
+			_, _, err := ec.CreateSimpleMessagePost(ch.Id, "hi", "")
+			return err
+		}},
+		{name: "read outside the channel", status: http.StatusNotFound, call: func() error {
+			_, _, err := ec.GetPost(root.Id, "")
+			return err
+		}},
+		{name: "reply to a missing root", status: http.StatusBadRequest, call: func() error {
+			_, _, err := ac.CreateSimpleMessagePost(ch.Id, "hi", mattermosttest.NewId())
+			return err
+		}},
+		{name: "patch someone else's post", status: http.StatusForbidden, call: func() error {
+			msg := "edited"
+			_, _, err := bc.PatchPost(root.Id, &mattermost.PostPatch{Message: &msg})
+			return err
+		}},
+		{name: "delete someone else's post", status: http.StatusForbidden, call: func() error {
+			_, err := bc.DeletePost(root.Id)
+			return err
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var appErr *mattermost.AppError
+			if err := tc.call(); !errors.As(err, &appErr) || appErr.StatusCode != tc.status {
+				t.Fatalf("err = %v, want status %d", err, tc.status)
+			}
+		})
+	}
+
+	t.Run("revalidation", func(t *testing.T) {
+		_, r, err := ac.GetPost(root.Id, "")
+		if err != nil || r.Etag == "" {
+			t.Fatalf("etag %q, err %v", r.Etag, err)
+		}
+		_, r, err = ac.GetPost(root.Id, r.Etag)
+		if err != nil || r.StatusCode != http.StatusNotModified {
+			t.Fatalf("status %d, err %v", r.StatusCode, err)
+		}
+	})
+
+	t.Run("delete cascades to replies", func(t *testing.T) {
+		if _, err := ac.DeletePost(root.Id); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range s.Posts(ch.Id) {
+			if p.DeleteAt == 0 {
+				t.Fatalf("post %q not deleted", p.Message)
+			}
+		}
+		if _, _, err := bc.GetPost(replies[1].Id, ""); !errors.Is(err, mattermost.ErrNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestServerDirectChannel(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+
+	first, _, err := s.Client(alice.Id).CreateDirectChannel(alice.Id, bob.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := s.Client(bob.Id).CreateDirectChannel(bob.Id, alice.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Id != second.Id || first.Type != mattermost.ChannelTypeDirect {
+		t.Fatalf("channels %+v and %+v", first, second)
+	}
+	if _, _, err := s.Client(alice.Id).CreateDirectChannel(alice.Id, mattermosttest.NewId()); !errors.Is(err, mattermost.ErrNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	s.SetRateLimit(2)
+	c := s.Client(u.Id)
+
+	for i, remaining := range []string{"1", "0"} {
+		_, r, err := c.GetMe("")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if r.Header.Get(mattermost.HeaderRateLimitLimit) != "2" || r.Header.Get(mattermost.HeaderRateLimitRemaining) != remaining {
+			t.Fatalf("request %d: headers %v", i, r.Header)
+		}
+	}
+	_, r, err := c.GetMe("")
+	if !errors.Is(err, mattermost.ErrRateLimited) || r.Header.Get(mattermost.HeaderRetryAfter) == "" {
+		t.Fatalf("err = %v, headers %v", err, r.Header)
+	}
+
+	// Sessions are limited separately.
+	if _, _, err := s.Client(u.Id).GetMe(""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerWebSocket(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	eve := s.AddUser(&mattermost.User{Username: "eve"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	s.AddChannelMember(ch.Id, bob.Id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	connect := func(userId string) *mattermost.WebSocketClient {
+		ws := mattermost.NewWebSocketClient(s.Client(userId))
+		go ws.Run(ctx)
+		if ev := <-ws.EventChannel; ev.Event != mattermost.WebsocketEventHello {
+			t.Fatalf("first event %+v", ev)
+		}
+		return ws
+	}
+	aliceWS, bobWS, eveWS := connect(alice.Id), connect(bob.Id), connect(eve.Id)
+
+	next := func(ws *mattermost.WebSocketClient) any {
+		t.Helper()
+		select {
+		case ev := <-ws.EventChannel:
+			decoded, err := mattermost.DecodeEvent(ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return decoded
+		case <-ctx.Done():
+			t.Fatal("no event")
+			return nil
+		}
+	}
+
+	if err := aliceWS.UserTyping(ch.Id, ""); err != nil {
+		t.Fatal(err)
+	}
+	if typing, ok := next(bobWS).(*mattermost.TypingEvent); !ok || *typing != (mattermost.TypingEvent{UserId: alice.Id, ChannelId: ch.Id}) {
+		t.Fatalf("bob got %#v", typing)
+	}
+
+	if _, _, err := s.Client(alice.Id).CreateSimpleMessagePost(ch.Id, "hi @bob", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, ws := range []*mattermost.WebSocketClient{aliceWS, bobWS} {
+		posted, ok := next(ws).(*mattermost.PostedEvent)
+		if !ok || posted.Post.Message != "hi @bob" || posted.SenderName != "@alice" || len(posted.Mentions) != 1 || posted.Mentions[0] != bob.Id {
+			t.Fatalf("got %#v", posted)
+		}
+	}
+
+	s.Broadcast(&mattermost.WebSocketEvent{
+		Event:     mattermost.WebsocketEventStatusChange,
+		Data:      map[string]any{"user_id": eve.Id, "status": "away"},
+		Broadcast: &mattermost.WebsocketBroadcast{UserId: eve.Id},
+	})
+	if status, ok := next(eveWS).(*mattermost.StatusChangeEvent); !ok || status.Status != "away" {
+		t.Fatalf("eve got %#v", status)
+	}
+
+	// Eve is not in the channel and sees none of its events.
+	select {
+	case ev := <-eveWS.EventChannel:
+		t.Fatalf("eve got %+v", ev)
+	default:
+	}
+}
@@ -0,0 +1,59 @@
+package mattermosttest
+
+import (
+	"net/http"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+func (s *Server) createUserAccessToken(w http.ResponseWriter, r *http.Request, userId string) {
+	var m map[string]string
+	if !decodeBody(w, r, &m) {
+		return
+	}
+	owner := r.PathValue("user_id")
+
+	s.mu.Lock()
+	if _, ok := s.users[owner]; !ok {
+		s.mu.Unlock()
+		writeError(w, r, "app.user.missing_account.const", "Unable to find the user.", http.StatusNotFound)
+		return
+	}
+	if owner != userId {
+		s.mu.Unlock()
+		writeError(w, r, "api.context.permissions.app_error", "You do not have the appropriate permissions.", http.StatusForbidden)
+		return
+	}
+	t := &mattermost.UserAccessToken{
+		Id:          NewId(),
+		Token:       NewId(),
+		UserId:      owner,
+		Description: m["description"],
+		IsActive:    true,
+	}
+	s.tokens[t.Id] = t
+	s.sessions[t.Token] = owner
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) revokeUserAccessToken(w http.ResponseWriter, r *http.Request, userId string) {
+	var m map[string]string
+	if !decodeBody(w, r, &m) {
+		return
+	}
+
+	s.mu.Lock()
+	t, ok := s.tokens[m["token_id"]]
+	if !ok || t.UserId != userId {
+		s.mu.Unlock()
+		writeError(w, r, "app.user_access_token.get_by_token.app_error", "Unable to find the token.", http.StatusNotFound)
+		return
+	}
+	delete(s.tokens, t.Id)
+	delete(s.sessions, t.Token)
+	s.mu.Unlock()
+
+	writeStatusOK(w)
+}
@@ -0,0 +1,186 @@
+package mattermosttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/websocket"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// socketBufferSize is how many events a socket holds before newer ones are
+// dropped, which a client sees as a gap in the sequence numbers.
+const socketBufferSize = 100
+
+var (
+	upgrader = websocket.Upgrader{}
+
+	mentionPattern = regexp.MustCompile(`@([a-z0-9.\-_]+)`)
+)
+
+// socket is a websocket connection of a user. Its fields are guarded by s.mu.
+type socket struct {
+	conn   *websocket.Conn
+	userId string
+	id     string
+	seq    int64
+	send   chan any
+}
+
+// Close closes the websocket connections and shuts the server down.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for sock := range s.sockets {
+		sock.conn.Close()
+	}
+	s.mu.Unlock()
+	s.Server.Close()
+}
+
+// Broadcast sends ev to the sockets selected by ev.Broadcast: the user in
+// UserId, else the members of ChannelId, else every connected user, minus the
+// users in OmitUsers. The sequence number is set per connection.
+func (s *Server) Broadcast(ev *mattermost.WebSocketEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcast(ev)
+}
+
+// broadcast is Broadcast with s.mu held.
+func (s *Server) broadcast(ev *mattermost.WebSocketEvent) {
+	b := ev.Broadcast
+	if b == nil {
+		b = &mattermost.WebsocketBroadcast{}
+	}
+	for sock := range s.sockets {
+		switch {
+		case b.OmitUsers[sock.userId]:
+			continue
+		case b.UserId != "":
+			if sock.userId != b.UserId {
+				continue
+			}
+		case b.ChannelId != "":
+			if s.members[b.ChannelId][sock.userId] == nil {
+				continue
+			}
+		}
+		s.sendEvent(sock, ev.Event, ev.Data, ev.Broadcast)
+	}
+}
+
+// sendEvent queues an event on sock, dropping it when the client is too slow.
+// s.mu must be held.
+func (s *Server) sendEvent(sock *socket, event string, data map[string]any, b *mattermost.WebsocketBroadcast) {
+	msg := &mattermost.WebSocketEvent{Event: event, Data: data, Broadcast: b, Sequence: sock.seq}
+	sock.seq++
+	select {
+	case sock.send <- msg:
+	default:
+	}
+}
+
+// publishPosted sends the posted event of p to the members of its channel.
+// s.mu must be held.
+func (s *Server) publishPosted(p *mattermost.Post) {
+	ch := s.channels[p.ChannelId]
+	postJSON, _ := json.Marshal(p)
+	var mentions []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(p.Message, -1) {
+		for _, u := range s.users {
+			if u.Username == m[1] && s.members[ch.Id][u.Id] != nil {
+				mentions = append(mentions, u.Id)
+			}
+		}
+	}
+	data := map[string]any{
+		"post":                 string(postJSON),
+		"channel_display_name": ch.DisplayName,
+		"channel_name":         ch.Name,
+		"channel_type":         string(ch.Type),
+		"team_id":              ch.TeamId,
+		"set_online":           true,
+	}
+	if u := s.users[p.UserId]; u != nil {
+		data["sender_name"] = "@" + u.Username
+	}
+	if mentions != nil {
+		mentionsJSON, _ := json.Marshal(mentions)
+		data["mentions"] = string(mentionsJSON)
+	}
+	s.broadcast(&mattermost.WebSocketEvent{
+		Event:     mattermost.WebsocketEventPosted,
+		Data:      data,
+		Broadcast: &mattermost.WebsocketBroadcast{ChannelId: ch.Id},
+	})
+}
+
+// connectWebSocket upgrades the connection and serves it until the client
+// closes it. Connections are not resumable: every connection gets a new id.
+func (s *Server) connectWebSocket(w http.ResponseWriter, r *http.Request, userId string) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sock := &socket{conn: conn, userId: userId, id: NewId(), send: make(chan any, socketBufferSize)}
+	s.mu.Lock()
+	s.sockets[sock] = true
+	s.sendEvent(sock, mattermost.WebsocketEventHello, map[string]any{"connection_id": sock.id, "server_version": "fake"}, &mattermost.WebsocketBroadcast{UserId: userId})
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	defer func() {
+		s.mu.Lock()
+		delete(s.sockets, sock)
+		s.mu.Unlock()
+		close(done)
+	}()
+	go func() {
+		for {
+			select {
+			case msg := <-sock.send:
+				if err := conn.WriteJSON(msg); err != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		var rq mattermost.WebSocketRequest
+		if err := conn.ReadJSON(&rq); err != nil {
+			return
+		}
+		rp := &mattermost.WebSocketResponse{Status: mattermost.StatusOk, SeqReply: rq.Seq}
+		s.mu.Lock()
+		switch rq.Action {
+		case mattermost.WebsocketAuthenticationChallenge:
+		case mattermost.WebsocketUserTypingAction:
+			channelId, _ := rq.Data["channel_id"].(string)
+			parentId, _ := rq.Data["parent_id"].(string)
+			if s.members[channelId][userId] == nil {
+				rp = &mattermost.WebSocketResponse{Status: mattermost.StatusFail, SeqReply: rq.Seq, Error: mattermost.NewAppError("user_typing", "api.websocket_handler.invalid_param.app_error", nil, "", http.StatusBadRequest)}
+				break
+			}
+			s.broadcast(&mattermost.WebSocketEvent{
+				Event:     mattermost.WebsocketEventTyping,
+				Data:      map[string]any{"user_id": userId, "parent_id": parentId},
+				Broadcast: &mattermost.WebsocketBroadcast{ChannelId: channelId, OmitUsers: map[string]bool{userId: true}},
+			})
+		default:
+			rp = &mattermost.WebSocketResponse{Status: mattermost.StatusFail, SeqReply: rq.Seq, Error: mattermost.NewAppError(rq.Action, "api.web_socket_router.no_action.app_error", nil, "", http.StatusNotImplemented)}
+		}
+		select {
+		case sock.send <- rp:
+		default:
+		}
+		s.mu.Unlock()
+	}
+}
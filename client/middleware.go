@@ -0,0 +1,37 @@
+package mattermost
+
+import "net/http"
+
+// RoundTripperFunc adapts an ordinary function to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(rq *http.Request) (*http.Response, error) {
+	return f(rq)
+}
+
+// Middleware wraps the round trip of every request sent by Client4. It is
+// called once per attempt, after authentication and client headers have been
+// set, and may modify the request, short-circuit it with its own response or
+// inspect the response returned by next.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Use appends middlewares to the client. The first middleware registered is the
+// outermost one and sees the request first and the response last.
+func (c *Client4) Use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// transport returns the round tripper for a single attempt, with every
+// registered middleware wrapped around HTTPClient.
+func (c *Client4) transport() http.RoundTripper {
+	var rt http.RoundTripper = RoundTripperFunc(c.HTTPClient.Do)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	return rt
+}
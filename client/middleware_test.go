@@ -0,0 +1,96 @@
+package mattermost_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "someone"}, "password")
+	c := s.Client(u.Id)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) mattermost.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+				mu.Lock()
+				calls = append(calls, name+" request")
+				mu.Unlock()
+				if rq.Header.Get(mattermost.HeaderAuth) == "" {
+					t.Errorf("%s: request not authenticated yet", name)
+				}
+				rp, err := next.RoundTrip(rq)
+				mu.Lock()
+				calls = append(calls, name+" response")
+				mu.Unlock()
+				return rp, err
+			})
+		}
+	}
+	c.Use(record("outer"), record("inner"))
+
+	if _, _, err := c.GetMe(""); err != nil {
+		t.Fatal(err)
+	}
+	want := "outer request,inner request,inner response,outer response"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "someone"}, "password")
+
+	for _, tc := range []struct {
+		name       string
+		middleware mattermost.Middleware
+		want       error
+	}{
+		{
+			name: "injected response",
+			middleware: func(next http.RoundTripper) http.RoundTripper {
+				return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusServiceUnavailable,
+						Status:     "503 Service Unavailable",
+						Header:     http.Header{},
+						Body:       io.NopCloser(strings.NewReader("maintenance")),
+						Request:    rq,
+					}, nil
+				})
+			},
+			want: mattermost.ErrServerUnavailable,
+		},
+		{
+			name: "injected error",
+			middleware: func(next http.RoundTripper) http.RoundTripper {
+				return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+					return nil, errFault
+				})
+			},
+			want: errFault,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := s.Client(u.Id)
+			c.Use(tc.middleware)
+			if _, _, err := c.GetMe(""); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+var errFault = errors.New("injected fault")
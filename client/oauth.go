@@ -0,0 +1,222 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	AccessTokenGrantType  = "authorization_code"
+	AccessTokenType       = "bearer"
+	RefreshTokenGrantType = "refresh_token"
+	AuthCodeResponseType  = "code"
+
+	OAuthAuthorizeURLSuffix   = "/oauth/authorize"
+	OAuthAccessTokenURLSuffix = "/oauth/access_token"
+)
+
+type OAuthApp struct {
+	Id              string      `json:"id"`
+	CreatorId       string      `json:"creator_id"`
+	CreateAt        int64       `json:"create_at"`
+	UpdateAt        int64       `json:"update_at"`
+	ClientSecret    string      `json:"client_secret"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	IconURL         string      `json:"icon_url"`
+	CallbackUrls    StringArray `json:"callback_urls"`
+	Homepage        string      `json:"homepage"`
+	IsTrusted       bool        `json:"is_trusted"`
+	MattermostAppID string      `json:"mattermost_app_id"`
+}
+
+// AccessResponse is the token returned by the /oauth/access_token endpoint.
+type AccessResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresInSeconds int32  `json:"expires_in"`
+	Scope            string `json:"scope"`
+	RefreshToken     string `json:"refresh_token"`
+	IdToken          string `json:"id_token"`
+}
+
+// CreateOAuthApp registers an OAuth 2.0 client application. The returned app
+// carries the client secret.
+func (c *Client4) CreateOAuthApp(app *OAuthApp) (*OAuthApp, *Response, error) {
+	return c.CreateOAuthAppContext(context.Background(), app)
+}
+
+func (c *Client4) CreateOAuthAppContext(ctx context.Context, app *OAuthApp) (*OAuthApp, *Response, error) {
+	appJSON, err := json.Marshal(app)
+	if err != nil {
+		return nil, nil, NewAppError("CreateOAuthApp", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	r, err := c.DoAPIPostContext(ctx, c.oAuthAppsRoute(), string(appJSON))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return decodeOAuthApp("CreateOAuthApp", r)
+}
+
+// GetOAuthApps returns a page of the OAuth applications visible to the user.
+func (c *Client4) GetOAuthApps(page, perPage int) ([]*OAuthApp, *Response, error) {
+	return c.GetOAuthAppsContext(context.Background(), page, perPage)
+}
+
+func (c *Client4) GetOAuthAppsContext(ctx context.Context, page, perPage int) ([]*OAuthApp, *Response, error) {
+	query := fmt.Sprintf("?page=%v&per_page=%v", page, perPage)
+	r, err := c.DoAPIGetContext(ctx, c.oAuthAppsRoute()+query, "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var list []*OAuthApp
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, nil, NewAppError("GetOAuthApps", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return list, BuildResponse(r), nil
+}
+
+// GetOAuthApp returns an OAuth application by id.
+func (c *Client4) GetOAuthApp(appId string) (*OAuthApp, *Response, error) {
+	return c.GetOAuthAppContext(context.Background(), appId)
+}
+
+func (c *Client4) GetOAuthAppContext(ctx context.Context, appId string) (*OAuthApp, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.oAuthAppRoute(appId), "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return decodeOAuthApp("GetOAuthApp", r)
+}
+
+// RegenerateOAuthAppSecret replaces the client secret of an OAuth application.
+func (c *Client4) RegenerateOAuthAppSecret(appId string) (*OAuthApp, *Response, error) {
+	return c.RegenerateOAuthAppSecretContext(context.Background(), appId)
+}
+
+func (c *Client4) RegenerateOAuthAppSecretContext(ctx context.Context, appId string) (*OAuthApp, *Response, error) {
+	r, err := c.DoAPIPostContext(ctx, c.oAuthAppRoute(appId)+"/regen_secret", "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return decodeOAuthApp("RegenerateOAuthAppSecret", r)
+}
+
+// DeleteOAuthApp deletes an OAuth application and revokes its access tokens.
+func (c *Client4) DeleteOAuthApp(appId string) (*Response, error) {
+	return c.DeleteOAuthAppContext(context.Background(), appId)
+}
+
+func (c *Client4) DeleteOAuthAppContext(ctx context.Context, appId string) (*Response, error) {
+	r, err := c.DoAPIDeleteContext(ctx, c.oAuthAppRoute(appId))
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
+func decodeOAuthApp(where string, r *http.Response) (*OAuthApp, *Response, error) {
+	var app OAuthApp
+	if err := json.NewDecoder(r.Body).Decode(&app); err != nil {
+		return nil, nil, NewAppError(where, "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &app, BuildResponse(r), nil
+}
+
+// OAuthConfig describes an OAuth application registered on a Mattermost
+// server and drives the authorization-code and refresh-token flows.
+type OAuthConfig struct {
+	ServerURL    string // The location of the server, for example "http://localhost:8065"
+	ClientId     string
+	ClientSecret string
+	RedirectURL  string       // Must match one of the callback URLs of the app
+	HTTPClient   *http.Client // Optional client used to reach the token endpoint
+}
+
+// AuthCodeURL returns the URL to send the user to in order to approve the
+// application. state is echoed back to the redirect URL and must be verified
+// by the caller to prevent cross-site request forgery.
+func (o *OAuthConfig) AuthCodeURL(state string) string {
+	v := url.Values{}
+	v.Set("response_type", AuthCodeResponseType)
+	v.Set("client_id", o.ClientId)
+	if o.RedirectURL != "" {
+		v.Set("redirect_uri", o.RedirectURL)
+	}
+	if state != "" {
+		v.Set("state", state)
+	}
+	return strings.TrimRight(o.ServerURL, "/") + OAuthAuthorizeURLSuffix + "?" + v.Encode()
+}
+
+// Exchange trades the authorization code received on the redirect URL for an
+// access token.
+func (o *OAuthConfig) Exchange(ctx context.Context, code string) (*AccessResponse, error) {
+	v := url.Values{}
+	v.Set("grant_type", AccessTokenGrantType)
+	v.Set("code", code)
+	v.Set("redirect_uri", o.RedirectURL)
+	return o.accessToken(ctx, v)
+}
+
+// Refresh obtains a new access token from a refresh token.
+func (o *OAuthConfig) Refresh(ctx context.Context, refreshToken string) (*AccessResponse, error) {
+	v := url.Values{}
+	v.Set("grant_type", RefreshTokenGrantType)
+	v.Set("refresh_token", refreshToken)
+	v.Set("redirect_uri", o.RedirectURL)
+	return o.accessToken(ctx, v)
+}
+
+// Client returns a Client4 acting on behalf of the user who granted token.
+func (o *OAuthConfig) Client(token *AccessResponse) *Client4 {
+	c := NewAPIv4Client(o.ServerURL)
+	if o.HTTPClient != nil {
+		c.HTTPClient = o.HTTPClient
+	}
+	c.SetToken(token.AccessToken)
+	return c
+}
+
+func (o *OAuthConfig) accessToken(ctx context.Context, v url.Values) (*AccessResponse, error) {
+	v.Set("client_id", o.ClientId)
+	v.Set("client_secret", o.ClientSecret)
+
+	c := NewAPIv4Client(o.ServerURL)
+	if o.HTTPClient != nil {
+		c.HTTPClient = o.HTTPClient
+	}
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	r, err := c.DoAPIRequestWithHeadersContext(ctx, http.MethodPost, c.URL+OAuthAccessTokenURLSuffix, v.Encode(), headers)
+	if err != nil {
+		var appErr *AppError
+		if errors.As(err, &appErr) {
+			appErr.IsOAuth = true
+		}
+		return nil, err
+	}
+	defer closeBody(r)
+
+	var token AccessResponse
+	if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+		appErr := NewAppError("OAuthConfig.accessToken", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		appErr.IsOAuth = true
+		return nil, appErr
+	}
+	if token.AccessToken == "" {
+		appErr := NewAppError("OAuthConfig.accessToken", "api.oauth.get_access_token.missing_token.app_error", nil, "", http.StatusInternalServerError)
+		appErr.IsOAuth = true
+		return nil, appErr
+	}
+	return &token, nil
+}
@@ -0,0 +1,214 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+const redirectURL = "https://app.example.com/callback"
+
+func TestOAuthApps(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	admin := s.AddUser(&mattermost.User{Username: "admin", Roles: "system_user system_admin"}, "secret")
+	c := s.Client(alice.Id)
+
+	app, _, err := c.CreateOAuthApp(&mattermost.OAuthApp{Name: "app", CallbackUrls: []string{redirectURL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Id == "" || app.ClientSecret == "" || app.CreatorId != alice.Id {
+		t.Fatalf("app %+v", app)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var appErr *mattermost.AppError
+		if _, _, err := c.CreateOAuthApp(&mattermost.OAuthApp{Name: "app"}); !errors.As(err, &appErr) || appErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("err = %v", err)
+		}
+	})
+
+	for _, tc := range []struct {
+		name    string
+		userId  string
+		wantErr error
+	}{
+		{name: "creator", userId: alice.Id},
+		{name: "admin", userId: admin.Id},
+		{name: "other user", userId: bob.Id, wantErr: mattermost.ErrForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := s.Client(tc.userId)
+			got, _, err := c.GetOAuthApp(app.Id)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && got.Name != "app" {
+				t.Fatalf("app %+v", got)
+			}
+			apps, _, err := c.GetOAuthApps(0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tc.wantErr == nil; (len(apps) == 1) != want {
+				t.Fatalf("apps %+v", apps)
+			}
+		})
+	}
+
+	t.Run("regenerate secret", func(t *testing.T) {
+		regenerated, _, err := c.RegenerateOAuthAppSecret(app.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if regenerated.ClientSecret == app.ClientSecret {
+			t.Fatal("secret not changed")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if _, err := s.Client(bob.Id).DeleteOAuthApp(app.Id); !errors.Is(err, mattermost.ErrForbidden) {
+			t.Fatalf("err = %v", err)
+		}
+		if _, err := c.DeleteOAuthApp(app.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.GetOAuthApp(app.Id); !errors.Is(err, mattermost.ErrNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestOAuthConfigAuthCodeURL(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config mattermost.OAuthConfig
+		state  string
+		want   url.Values
+	}{
+		{
+			name:   "with state",
+			config: mattermost.OAuthConfig{ServerURL: "https://chat.example.com/", ClientId: "client", RedirectURL: redirectURL},
+			state:  "xyz",
+			want:   url.Values{"response_type": {"code"}, "client_id": {"client"}, "redirect_uri": {redirectURL}, "state": {"xyz"}},
+		},
+		{
+			name:   "defaults",
+			config: mattermost.OAuthConfig{ServerURL: "https://chat.example.com", ClientId: "client"},
+			want:   url.Values{"response_type": {"code"}, "client_id": {"client"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.config.AuthCodeURL(tc.state))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Scheme+"://"+u.Host+u.Path != "https://chat.example.com/oauth/authorize" {
+				t.Fatalf("url %s", u)
+			}
+			if got := u.Query(); got.Encode() != tc.want.Encode() {
+				t.Fatalf("query %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOAuthConfigFlow(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	owner := s.Client(alice.Id)
+	app, _, err := owner.CreateOAuthApp(&mattermost.OAuthApp{Name: "app", CallbackUrls: []string{redirectURL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &mattermost.OAuthConfig{ServerURL: s.URL, ClientId: app.Id, ClientSecret: app.ClientSecret, RedirectURL: redirectURL}
+	ctx := context.Background()
+
+	// oauthError checks that err is an OAuth error with status.
+	oauthError := func(t *testing.T, err error, status int) {
+		t.Helper()
+		var appErr *mattermost.AppError
+		if !errors.As(err, &appErr) || !appErr.IsOAuth || appErr.StatusCode != status {
+			t.Fatalf("err = %v, want an OAuth error with status %d", err, status)
+		}
+	}
+
+	code := s.AuthorizeOAuthApp(app.Id, bob.Id, redirectURL)
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.TokenType != mattermost.AccessTokenType || token.RefreshToken == "" {
+		t.Fatalf("token %+v", token)
+	}
+	me, _, err := config.Client(token).GetMe("")
+	if err != nil || me.Id != bob.Id {
+		t.Fatalf("GetMe = %+v, %v", me, err)
+	}
+
+	t.Run("code used twice", func(t *testing.T) {
+		_, err := config.Exchange(ctx, code)
+		oauthError(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("wrong redirect", func(t *testing.T) {
+		other := *config
+		other.RedirectURL = "https://evil.example.com/callback"
+		_, err := other.Exchange(ctx, s.AuthorizeOAuthApp(app.Id, bob.Id, redirectURL))
+		oauthError(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		other := *config
+		other.ClientSecret = "wrong"
+		_, err := other.Exchange(ctx, s.AuthorizeOAuthApp(app.Id, bob.Id, redirectURL))
+		oauthError(t, err, http.StatusForbidden)
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		refreshed, err := config.Refresh(ctx, token.RefreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refreshed.AccessToken == token.AccessToken {
+			t.Fatal("access token not renewed")
+		}
+		if _, _, err := config.Client(refreshed).GetMe(""); err != nil {
+			t.Fatal(err)
+		}
+		_, err = config.Refresh(ctx, token.RefreshToken)
+		oauthError(t, err, http.StatusBadRequest)
+		token = refreshed
+	})
+
+	t.Run("http client", func(t *testing.T) {
+		var used bool
+		other := *config
+		other.HTTPClient = &http.Client{Transport: mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+			used = true
+			return http.DefaultTransport.RoundTrip(rq)
+		})}
+		if _, err := other.Exchange(ctx, s.AuthorizeOAuthApp(app.Id, bob.Id, redirectURL)); err != nil || !used {
+			t.Fatalf("err = %v, used %v", err, used)
+		}
+	})
+
+	t.Run("app deleted", func(t *testing.T) {
+		if _, err := owner.DeleteOAuthApp(app.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := config.Client(token).GetMe(""); !errors.Is(err, mattermost.ErrUnauthorized) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
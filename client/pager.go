@@ -0,0 +1,85 @@
+package mattermost
+
+import (
+	"context"
+	"iter"
+)
+
+// DefaultPerPage is the page size used by the iterators. It is the largest
+// page the server accepts for most list endpoints.
+const DefaultPerPage = 200
+
+// PageFunc fetches one page of a paginated list endpoint.
+type PageFunc[T any] func(ctx context.Context, page, perPage int) ([]T, *Response, error)
+
+// Paginate returns an iterator over every item of a paginated endpoint. While
+// a page is being consumed the next one is fetched in the background. Breaking
+// out of the loop cancels the pending fetch. A failed fetch is yielded once
+// with the zero value and ends the iteration.
+//
+// The iteration ends at the first empty page rather than the first short one,
+// as the server may return fewer than perPage items when it caps the page size.
+func Paginate[T any](ctx context.Context, perPage int, fetch PageFunc[T]) iter.Seq2[T, error] {
+	if perPage <= 0 {
+		perPage = DefaultPerPage
+	}
+
+	type result struct {
+		items []T
+		err   error
+	}
+
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		fetchAsync := func(page int) <-chan result {
+			ch := make(chan result, 1)
+			go func() {
+				items, _, err := fetch(ctx, page, perPage)
+				ch <- result{items, err}
+			}()
+			return ch
+		}
+
+		next := fetchAsync(0)
+		for page := 0; ; page++ {
+			res := <-next
+			if res.err != nil {
+				var zero T
+				yield(zero, res.err)
+				return
+			}
+
+			if len(res.items) == 0 {
+				return
+			}
+			next = fetchAsync(page + 1)
+
+			for _, item := range res.items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AllChannelMembers iterates over every member of a channel.
+func (c *Client4) AllChannelMembers(ctx context.Context, channelId string) iter.Seq2[ChannelMember, error] {
+	return Paginate(ctx, DefaultPerPage, func(ctx context.Context, page, perPage int) ([]ChannelMember, *Response, error) {
+		return c.GetChannelMembersContext(ctx, channelId, page, perPage, "")
+	})
+}
+
+// AllUserAccessTokensForUser iterates over every personal access token of a user.
+func (c *Client4) AllUserAccessTokensForUser(ctx context.Context, userId string) iter.Seq2[*UserAccessToken, error] {
+	return Paginate(ctx, DefaultPerPage, func(ctx context.Context, page, perPage int) ([]*UserAccessToken, *Response, error) {
+		return c.GetUserAccessTokensForUserContext(ctx, userId, page, perPage)
+	})
+}
+
+// AllOAuthApps iterates over every OAuth application visible to the user.
+func (c *Client4) AllOAuthApps(ctx context.Context) iter.Seq2[*OAuthApp, error] {
+	return Paginate(ctx, DefaultPerPage, c.GetOAuthAppsContext)
+}
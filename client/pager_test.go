@@ -0,0 +1,185 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+// pages returns a PageFunc over the integers 0 to n-1 that returns at most
+// limit items per page, like a server capping the page size.
+func pages(n, limit int, calls *atomic.Int32) mattermost.PageFunc[int] {
+	return func(ctx context.Context, page, perPage int) ([]int, *mattermost.Response, error) {
+		calls.Add(1)
+		size := min(perPage, limit)
+		var items []int
+		for i := page * size; i < min((page+1)*size, n); i++ {
+			items = append(items, i)
+		}
+		return items, nil, nil
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		n         int
+		perPage   int
+		limit     int
+		wantCalls int32
+	}{
+		{name: "empty", n: 0, perPage: 10, limit: 10, wantCalls: 1},
+		{name: "short last page", n: 25, perPage: 10, limit: 10, wantCalls: 4},
+		{name: "exact pages", n: 30, perPage: 10, limit: 10, wantCalls: 4},
+		{name: "server caps the page size", n: 450, perPage: 500, limit: 200, wantCalls: 4},
+		{name: "default page size", n: 450, perPage: 0, limit: 1000, wantCalls: 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			got := 0
+			for item, err := range mattermost.Paginate(context.Background(), tc.perPage, pages(tc.n, tc.limit, &calls)) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item != got {
+					t.Fatalf("item %d, want %d", item, got)
+				}
+				got++
+			}
+			if got != tc.n {
+				t.Fatalf("%d items, want %d", got, tc.n)
+			}
+			if calls.Load() != tc.wantCalls {
+				t.Fatalf("%d fetches, want %d", calls.Load(), tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPaginateBreak(t *testing.T) {
+	cancelled := make(chan struct{})
+	fetch := func(ctx context.Context, page, perPage int) ([]int, *mattermost.Response, error) {
+		if page == 0 {
+			return []int{0, 1, 2}, nil, nil
+		}
+		<-ctx.Done()
+		close(cancelled)
+		return nil, nil, ctx.Err()
+	}
+	for item := range mattermost.Paginate(context.Background(), 3, fetch) {
+		if item == 1 {
+			break
+		}
+	}
+	<-cancelled
+}
+
+func TestPaginateError(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	fetch := func(ctx context.Context, page, perPage int) ([]int, *mattermost.Response, error) {
+		if page == 2 {
+			return nil, nil, errFetch
+		}
+		return []int{page*2 + 0, page*2 + 1}, nil, nil
+	}
+	var items []int
+	var errs []error
+	for item, err := range mattermost.Paginate(context.Background(), 2, fetch) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	if fmt.Sprint(items) != "[0 1 2 3]" || len(errs) != 1 || !errors.Is(errs[0], errFetch) {
+		t.Fatalf("items %v, errors %v", items, errs)
+	}
+}
+
+func TestAllChannelMembers(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	owner := s.AddUser(&mattermost.User{Username: "owner"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	want := map[string]bool{}
+	for i := 0; i < 450; i++ {
+		u := s.AddUser(&mattermost.User{Username: fmt.Sprintf("user%d", i)}, "secret")
+		s.AddChannelMember(ch.Id, u.Id)
+		want[u.Id] = true
+	}
+
+	c := s.Client(owner.Id)
+	got := map[string]bool{}
+	for m, err := range c.AllChannelMembers(context.Background(), ch.Id) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[m.UserId] = true
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d members, want %d", len(got), len(want))
+	}
+
+	// A page size above the server's cap must not end the iteration early.
+	n := 0
+	for _, err := range mattermost.Paginate(context.Background(), 500, func(ctx context.Context, page, perPage int) ([]mattermost.ChannelMember, *mattermost.Response, error) {
+		return c.GetChannelMembersContext(ctx, ch.Id, page, perPage, "")
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Fatalf("%d members with a capped page size, want %d", n, len(want))
+	}
+
+	for _, err := range c.AllChannelMembers(context.Background(), mattermosttest.NewId()) {
+		if !errors.Is(err, mattermost.ErrNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+}
+
+func TestUpdateThreadFollowAllUsersInChannel(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	team := s.AddTeam(&mattermost.Team{Name: "team"})
+	ch := s.AddChannel(&mattermost.Channel{TeamId: team.Id, Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	var members []*mattermost.User
+	// More members than fit in the largest page.
+	for i := 0; i < 250; i++ {
+		u := s.AddUser(&mattermost.User{Username: fmt.Sprintf("user%d", i)}, "secret")
+		s.AddChannelMember(ch.Id, u.Id)
+		members = append(members, u)
+	}
+	outsider := s.AddUser(&mattermost.User{Username: "outsider"}, "secret")
+	c := s.Client(members[0].Id)
+	root, _, err := c.CreateSimpleMessagePost(ch.Id, "thread", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []bool{true, false} {
+		if err := c.UpdateThreadFollowAllUsersInChannel(ch.Id, root.Id, state); err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range members {
+			if s.IsFollowing(u.Id, root.Id) != state {
+				t.Fatalf("%s following %v, want %v", u.Username, !state, state)
+			}
+		}
+		if s.IsFollowing(outsider.Id, root.Id) {
+			t.Fatal("a user outside the channel follows the thread")
+		}
+	}
+
+	if err := c.UpdateThreadFollowAllUsersInChannel(mattermosttest.NewId(), root.Id, true); !errors.Is(err, mattermost.ErrNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+}
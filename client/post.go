@@ -4,6 +4,8 @@
 package mattermost
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
@@ -72,21 +74,75 @@ const (
 type StringInterface map[string]interface{}
 type StringArray []string
 
+// SlackCompatibleBool is a bool that also accepts the strings "true" and
+// "false", as Slack compatible integrations and older posts send them.
+type SlackCompatibleBool bool
+
+func (b *SlackCompatibleBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = SlackCompatibleBool(value)
+		return nil
+	}
+	var stringValue string
+	if err := json.Unmarshal(data, &stringValue); err != nil {
+		return err
+	}
+	*b = stringValue == "true"
+	return nil
+}
+
 type MsgAttachmentField struct {
-	Short string `json:"short"`
-	Title string `json:"title"`
-	Value string `json:"value"`
+	Short SlackCompatibleBool `json:"short"`
+	Title string              `json:"title"`
+	Value string              `json:"value"`
 }
+
+// UnmarshalJSON also accepts a Value that is not a string, such as a number
+// sent by a Slack compatible integration, keeping its JSON text.
+func (f *MsgAttachmentField) UnmarshalJSON(data []byte) error {
+	type field MsgAttachmentField
+	aux := struct {
+		*field
+		Value json.RawMessage `json:"value"`
+	}{field: (*field)(f)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	switch value := bytes.TrimSpace(aux.Value); {
+	case len(value) == 0 || string(value) == "null":
+		f.Value = ""
+	case value[0] == '"':
+		return json.Unmarshal(value, &f.Value)
+	default:
+		f.Value = string(value)
+	}
+	return nil
+}
+
+// MsgAttachment is a Slack compatible message attachment, stored in the
+// "attachments" prop of a post. TitleLink is only rendered when Title is set,
+// and Fallback is the plain text shown where attachments cannot be, such as
+// notifications.
 type MsgAttachment struct {
-	Author    string               `json:"author_name"`
-	Color     string               `json:"color"`
-	Title     string               `json:"title"`
-	TitleLink string               `json:"title_link"`
-	ThumbUrl  string               `json:"thumb_url"`
-	Text      string               `json:"text"`
-	Pretext   string               `json:"pretext"`
-	Footer    string               `json:"footer"`
-	Fields    []MsgAttachmentField `json:"fields"`
+	Id         int64                `json:"id"`
+	Fallback   string               `json:"fallback"`
+	Color      string               `json:"color"`
+	Pretext    string               `json:"pretext"`
+	Author     string               `json:"author_name"`
+	AuthorLink string               `json:"author_link"`
+	AuthorIcon string               `json:"author_icon"`
+	Title      string               `json:"title"`
+	TitleLink  string               `json:"title_link"`
+	Text       string               `json:"text"`
+	Fields     []MsgAttachmentField `json:"fields"`
+	ImageURL   string               `json:"image_url"`
+	ThumbUrl   string               `json:"thumb_url"`
+	Footer     string               `json:"footer"`
+	FooterIcon string               `json:"footer_icon"`
+	Timestamp  any                  `json:"ts"` // Unix time in seconds, either a string or a number
+	Actions    []*PostAction        `json:"actions,omitempty"`
 }
 
 type MsgProperties struct {
@@ -134,6 +190,17 @@ type Post struct {
 	//LastReplyAt int64 `json:"last_reply_at,omitempty"`
 	IsFollowing *bool `json:"is_following,omitempty"` // for root posts in collapsed thread mode indicates if the current user is following this thread
 }
+
+// PostPatch holds the fields of a post to change with PatchPost. Nil fields
+// are left unchanged.
+type PostPatch struct {
+	IsPinned     *bool          `json:"is_pinned,omitempty"`
+	Message      *string        `json:"message,omitempty"`
+	Props        *MsgProperties `json:"props,omitempty"`
+	FileIds      *StringArray   `json:"file_ids,omitempty"`
+	HasReactions *bool          `json:"has_reactions,omitempty"`
+}
+
 type SimplePost struct {
 	ChannelId string `json:"channel_id"`
 	RootId    string `json:"root_id"`
@@ -141,11 +208,15 @@ type SimplePost struct {
 }
 
 func (c *Client4) CreateSimplePost(post *SimplePost) (*Post, *Response, error) {
+	return c.CreateSimplePostContext(context.Background(), post)
+}
+
+func (c *Client4) CreateSimplePostContext(ctx context.Context, post *SimplePost) (*Post, *Response, error) {
 	postJSON, err := json.Marshal(post)
 	if err != nil {
 		return nil, nil, NewAppError("CreatePost", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
-	r, err := c.DoAPIPost(c.postsRoute(), string(postJSON))
+	r, err := c.DoAPIPostContext(ctx, c.postsRoute(), string(postJSON))
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -161,11 +232,15 @@ func (c *Client4) CreateSimplePost(post *SimplePost) (*Post, *Response, error) {
 }
 
 func (c *Client4) CreatePost(post *Post) (*Post, *Response, error) {
+	return c.CreatePostContext(context.Background(), post)
+}
+
+func (c *Client4) CreatePostContext(ctx context.Context, post *Post) (*Post, *Response, error) {
 	postJSON, err := json.Marshal(post)
 	if err != nil {
 		return nil, nil, NewAppError("CreatePost", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
-	r, err := c.DoAPIPost(c.postsRoute(), string(postJSON))
+	r, err := c.DoAPIPostContext(ctx, c.postsRoute(), string(postJSON))
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -181,17 +256,26 @@ func (c *Client4) CreatePost(post *Post) (*Post, *Response, error) {
 }
 
 func (c *Client4) CreateSimpleMessagePost(channelId, message, rootId string) (*Post, *Response, error) {
+	return c.CreateSimpleMessagePostContext(context.Background(), channelId, message, rootId)
+}
+
+func (c *Client4) CreateSimpleMessagePostContext(ctx context.Context, channelId, message, rootId string) (*Post, *Response, error) {
 	post := &SimplePost{
 		RootId:    rootId,
 		ChannelId: channelId,
 		Message:   message,
 	}
-	return c.CreateSimplePost(post)
+	return c.CreateSimplePostContext(ctx, post)
 }
 func (c *Client4) CreatePostWithAttachtent(
 	channel, message, rootId string, msgProperties MsgProperties, msgMetadata MsgMetadata) (*Post, *Response, error) {
+	return c.CreatePostWithAttachtentContext(context.Background(), channel, message, rootId, msgProperties, msgMetadata)
+}
+
+func (c *Client4) CreatePostWithAttachtentContext(
+	ctx context.Context, channel, message, rootId string, msgProperties MsgProperties, msgMetadata MsgMetadata) (*Post, *Response, error) {
 	//	attachmentColor := GetAttachmentColor(messageLevel)
-	channelId, err := PrepareChannelId(c, channel)
+	channelId, err := PrepareChannelIdContext(ctx, c, channel)
 	if err != nil {
 		channelId = channel
 	}
@@ -202,16 +286,20 @@ func (c *Client4) CreatePostWithAttachtent(
 		Properties: msgProperties,
 		Metadata:   &msgMetadata,
 	}
-	return c.CreatePost(post)
+	return c.CreatePostContext(ctx, post)
 }
 
 // UpdatePost updates a post based on the provided post struct.
 func (c *Client4) UpdatePost(postId string, post *Post) (*Post, *Response, error) {
+	return c.UpdatePostContext(context.Background(), postId, post)
+}
+
+func (c *Client4) UpdatePostContext(ctx context.Context, postId string, post *Post) (*Post, *Response, error) {
 	postJSON, err := json.Marshal(post)
 	if err != nil {
 		return nil, nil, NewAppError("UpdatePost", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
-	r, err := c.DoAPIPut(c.postRoute(postId), string(postJSON))
+	r, err := c.DoAPIPutContext(ctx, c.postRoute(postId), string(postJSON))
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -227,10 +315,119 @@ func (c *Client4) UpdatePost(postId string, post *Post) (*Post, *Response, error
 }
 func (c *Client4) UpdatePostWithAttachtent(
 	postId, message string, msgProperties MsgProperties) (*Post, *Response, error) {
+	return c.UpdatePostWithAttachtentContext(context.Background(), postId, message, msgProperties)
+}
+
+func (c *Client4) UpdatePostWithAttachtentContext(
+	ctx context.Context, postId, message string, msgProperties MsgProperties) (*Post, *Response, error) {
 	post := &Post{
 		Id:         postId,
 		Message:    message,
 		Properties: msgProperties,
 	}
-	return c.UpdatePost(postId, post)
+	return c.UpdatePostContext(ctx, postId, post)
+}
+
+// GetPost gets a single post.
+func (c *Client4) GetPost(postId string, etag string) (*Post, *Response, error) {
+	return c.GetPostContext(context.Background(), postId, etag)
+}
+
+func (c *Client4) GetPostContext(ctx context.Context, postId string, etag string) (*Post, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.postRoute(postId), etag)
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var p Post
+	if r.StatusCode == http.StatusNotModified {
+		return &p, BuildResponse(r), nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		return nil, nil, NewAppError("GetPost", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &p, BuildResponse(r), nil
+}
+
+// GetPostsByIds gets a list of posts by their ids. Posts the user cannot
+// read are left out.
+func (c *Client4) GetPostsByIds(postIds []string) ([]*Post, *Response, error) {
+	return c.GetPostsByIdsContext(context.Background(), postIds)
+}
+
+func (c *Client4) GetPostsByIdsContext(ctx context.Context, postIds []string) ([]*Post, *Response, error) {
+	r, err := c.DoAPIPostContext(ctx, c.postsRoute()+"/ids", ArrayToJSON(postIds))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var list []*Post
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, nil, NewAppError("GetPostsByIds", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return list, BuildResponse(r), nil
+}
+
+// DeletePost deletes a post and, when it is a root post, its replies.
+func (c *Client4) DeletePost(postId string) (*Response, error) {
+	return c.DeletePostContext(context.Background(), postId)
+}
+
+func (c *Client4) DeletePostContext(ctx context.Context, postId string) (*Response, error) {
+	r, err := c.DoAPIDeleteContext(ctx, c.postRoute(postId))
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
+// PatchPost partially updates a post. Only the non-nil fields of patch are
+// changed, so unlike UpdatePost it leaves the props alone unless patch.Props
+// is set.
+func (c *Client4) PatchPost(postId string, patch *PostPatch) (*Post, *Response, error) {
+	return c.PatchPostContext(context.Background(), postId, patch)
+}
+
+func (c *Client4) PatchPostContext(ctx context.Context, postId string, patch *PostPatch) (*Post, *Response, error) {
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return nil, nil, NewAppError("PatchPost", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	r, err := c.DoAPIPutContext(ctx, c.postRoute(postId)+"/patch", string(patchJSON))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var p Post
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		return nil, nil, NewAppError("PatchPost", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &p, BuildResponse(r), nil
+}
+
+// GetPostThread gets the post and the rest of its thread. Use PostList.Thread
+// to get the root post followed by its replies in order.
+func (c *Client4) GetPostThread(postId string, etag string, collapsedThreads bool) (*PostList, *Response, error) {
+	return c.GetPostThreadContext(context.Background(), postId, etag, collapsedThreads)
+}
+
+func (c *Client4) GetPostThreadContext(ctx context.Context, postId string, etag string, collapsedThreads bool) (*PostList, *Response, error) {
+	url := c.postRoute(postId) + "/thread"
+	if collapsedThreads {
+		url += "?collapsedThreads=" + c.boolString(true)
+	}
+	r, err := c.DoAPIGetContext(ctx, url, etag)
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	list := NewPostList()
+	if r.StatusCode == http.StatusNotModified {
+		return list, BuildResponse(r), nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(list); err != nil {
+		return nil, nil, NewAppError("GetPostThread", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return list, BuildResponse(r), nil
 }
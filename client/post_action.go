@@ -0,0 +1,203 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+const (
+	PostActionTypeButton = "button"
+	PostActionTypeSelect = "select"
+
+	PostActionDataSourceUsers    = "users"
+	PostActionDataSourceChannels = "channels"
+
+	PostActionStyleDefault = "default"
+	PostActionStylePrimary = "primary"
+	PostActionStyleSuccess = "success"
+	PostActionStyleGood    = "good"
+	PostActionStyleWarning = "warning"
+	PostActionStyleDanger  = "danger"
+
+	// PostActionContextKey is the integration context entry naming the
+	// handler an ActionRouter dispatches a request to.
+	PostActionContextKey = "action"
+
+	// PostActionSelectedOption is the context entry in which the server
+	// passes the value picked in a select menu.
+	PostActionSelectedOption = "selected_option"
+
+	postActionMaxBodySize = 1 << 20
+)
+
+type PostActionOptions struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+// PostActionIntegration is where the server sends a request when the action is
+// used. Context is passed back unchanged in the request.
+type PostActionIntegration struct {
+	URL     string         `json:"url,omitempty"`
+	Context map[string]any `json:"context,omitempty"`
+}
+
+// PostAction is a button or a select menu of a message attachment. Select
+// menus list Options, or the users or channels of the server when DataSource
+// is set. The server assigns Id when it is empty; it may only contain letters
+// and digits.
+type PostAction struct {
+	Id            string                 `json:"id,omitempty"`
+	Type          string                 `json:"type,omitempty"`
+	Name          string                 `json:"name,omitempty"`
+	Disabled      bool                   `json:"disabled,omitempty"`
+	Style         string                 `json:"style,omitempty"`
+	DataSource    string                 `json:"data_source,omitempty"`
+	Options       []*PostActionOptions   `json:"options,omitempty"`
+	DefaultOption string                 `json:"default_option,omitempty"`
+	Integration   *PostActionIntegration `json:"integration,omitempty"`
+	Cookie        string                 `json:"cookie,omitempty"` // Set by the server, do not change
+}
+
+// PostActionIntegrationRequest is sent by the server to the integration URL
+// when a user clicks a button or picks an option.
+type PostActionIntegrationRequest struct {
+	UserId      string         `json:"user_id"`
+	UserName    string         `json:"user_name"`
+	ChannelId   string         `json:"channel_id"`
+	ChannelName string         `json:"channel_name"`
+	TeamId      string         `json:"team_id"`
+	TeamName    string         `json:"team_domain"`
+	PostId      string         `json:"post_id"`
+	TriggerId   string         `json:"trigger_id"` // Allows opening an interactive dialog for a short time
+	Type        string         `json:"type"`
+	DataSource  string         `json:"data_source"`
+	Context     map[string]any `json:"context,omitempty"`
+}
+
+// SelectedOption returns the value picked in a select menu.
+func (r *PostActionIntegrationRequest) SelectedOption() string {
+	s, _ := r.Context[PostActionSelectedOption].(string)
+	return s
+}
+
+// PostActionIntegrationResponse is the integration's answer. Update replaces
+// the message and props of the post the action belongs to, and EphemeralText
+// is shown only to the user who used the action.
+type PostActionIntegrationResponse struct {
+	Update           *Post  `json:"update,omitempty"`
+	EphemeralText    string `json:"ephemeral_text,omitempty"`
+	SkipSlackParsing bool   `json:"skip_slack_parsing,omitempty"`
+	GotoLocation     string `json:"goto_location,omitempty"`
+}
+
+// NewPostButton returns a button sending its requests to url, dispatched by an
+// ActionRouter to the handler registered for action. Entries of context are
+// passed back in the request.
+func NewPostButton(name, url, action string, context map[string]any) *PostAction {
+	return &PostAction{
+		Type:        PostActionTypeButton,
+		Name:        name,
+		Integration: newPostActionIntegration(url, action, context),
+	}
+}
+
+// NewPostSelect returns a select menu listing options, dispatched like the
+// buttons of NewPostButton. Use PostActionIntegrationRequest.SelectedOption
+// to get the choice.
+func NewPostSelect(name, url, action string, context map[string]any, options ...*PostActionOptions) *PostAction {
+	return &PostAction{
+		Type:        PostActionTypeSelect,
+		Name:        name,
+		Options:     options,
+		Integration: newPostActionIntegration(url, action, context),
+	}
+}
+
+// NewPostSourceSelect returns a select menu listing the users or the channels
+// of the server, depending on dataSource.
+func NewPostSourceSelect(name, url, action, dataSource string, context map[string]any) *PostAction {
+	return &PostAction{
+		Type:        PostActionTypeSelect,
+		Name:        name,
+		DataSource:  dataSource,
+		Integration: newPostActionIntegration(url, action, context),
+	}
+}
+
+func newPostActionIntegration(url, action string, context map[string]any) *PostActionIntegration {
+	ctx := make(map[string]any, len(context)+1)
+	for k, v := range context {
+		ctx[k] = v
+	}
+	ctx[PostActionContextKey] = action
+	return &PostActionIntegration{URL: url, Context: ctx}
+}
+
+// ActionHandlerFunc handles an integration request. A nil response is
+// answered with an empty one, leaving the post unchanged.
+type ActionHandlerFunc func(ctx context.Context, rq *PostActionIntegrationRequest) (*PostActionIntegrationResponse, error)
+
+// ActionRouter is the http.Handler receiving the integration requests of post
+// actions. It dispatches them on the PostActionContextKey entry of their
+// context to the handlers registered with Handle. Requests are not
+// authenticated by the server, so add a secret to the action context and
+// check it in the handlers when the URL is reachable by others.
+type ActionRouter struct {
+	// ErrorHandler is called when a handler fails, before the server is
+	// answered with 500. It defaults to doing nothing.
+	ErrorHandler func(rq *PostActionIntegrationRequest, err error)
+
+	mu       sync.RWMutex
+	handlers map[string]ActionHandlerFunc
+}
+
+func NewActionRouter() *ActionRouter {
+	return &ActionRouter{handlers: map[string]ActionHandlerFunc{}}
+}
+
+// Handle registers the handler for action, replacing any previous one.
+func (ar *ActionRouter) Handle(action string, handler ActionHandlerFunc) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.handlers[action] = handler
+}
+
+func (ar *ActionRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rq PostActionIntegrationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, postActionMaxBodySize)).Decode(&rq); err != nil {
+		http.Error(w, "invalid integration request", http.StatusBadRequest)
+		return
+	}
+
+	action, _ := rq.Context[PostActionContextKey].(string)
+	ar.mu.RLock()
+	handler, ok := ar.handlers[action]
+	ar.mu.RUnlock()
+	if !ok {
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	}
+
+	rp, err := handler(r.Context(), &rq)
+	if err != nil {
+		if ar.ErrorHandler != nil {
+			ar.ErrorHandler(&rq, err)
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if rp == nil {
+		rp = &PostActionIntegrationResponse{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rp)
+}
@@ -0,0 +1,206 @@
+package mattermost_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestPostActionBuilders(t *testing.T) {
+	actionContext := map[string]any{"secret": "s3cret", "ticket": float64(42)}
+	options := []*mattermost.PostActionOptions{{Text: "Low", Value: "low"}, {Text: "High", Value: "high"}}
+
+	for _, tc := range []struct {
+		name           string
+		action         *mattermost.PostAction
+		wantType       string
+		wantDataSource string
+		wantOptions    []*mattermost.PostActionOptions
+	}{
+		{
+			name:     "button",
+			action:   mattermost.NewPostButton("Approve", "https://bot.example.com/actions", "approve", actionContext),
+			wantType: mattermost.PostActionTypeButton,
+		},
+		{
+			name:        "select",
+			action:      mattermost.NewPostSelect("Priority", "https://bot.example.com/actions", "approve", actionContext, options...),
+			wantType:    mattermost.PostActionTypeSelect,
+			wantOptions: options,
+		},
+		{
+			name:           "users",
+			action:         mattermost.NewPostSourceSelect("Assignee", "https://bot.example.com/actions", "approve", mattermost.PostActionDataSourceUsers, actionContext),
+			wantType:       mattermost.PostActionTypeSelect,
+			wantDataSource: mattermost.PostActionDataSourceUsers,
+		},
+		{
+			name:           "channels",
+			action:         mattermost.NewPostSourceSelect("Channel", "https://bot.example.com/actions", "approve", mattermost.PostActionDataSourceChannels, actionContext),
+			wantType:       mattermost.PostActionTypeSelect,
+			wantDataSource: mattermost.PostActionDataSourceChannels,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := tc.action
+			if a.Type != tc.wantType || a.DataSource != tc.wantDataSource || !reflect.DeepEqual(a.Options, tc.wantOptions) {
+				t.Fatalf("action %+v", a)
+			}
+			want := map[string]any{"secret": "s3cret", "ticket": float64(42), mattermost.PostActionContextKey: "approve"}
+			if a.Integration.URL != "https://bot.example.com/actions" || !reflect.DeepEqual(a.Integration.Context, want) {
+				t.Fatalf("integration %+v", a.Integration)
+			}
+		})
+	}
+	if _, ok := actionContext[mattermost.PostActionContextKey]; ok {
+		t.Fatal("the context passed to the builder was changed")
+	}
+
+	t.Run("stored in a post", func(t *testing.T) {
+		s := mattermosttest.NewServer()
+		defer s.Close()
+		alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+		ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+		s.AddChannelMember(ch.Id, alice.Id)
+		c := s.Client(alice.Id)
+
+		actions := []*mattermost.PostAction{
+			mattermost.NewPostButton("Approve", "https://bot.example.com/actions", "approve", actionContext),
+			mattermost.NewPostSelect("Priority", "https://bot.example.com/actions", "priority", nil, options...),
+		}
+		created, _, err := c.CreatePost(&mattermost.Post{
+			ChannelId:  ch.Id,
+			Properties: mattermost.MsgProperties{Attachments: []mattermost.MsgAttachment{{Text: "Deploy?", Actions: actions}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := c.GetPost(created.Id, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Properties.Attachments[0].Actions, actions) {
+			t.Fatalf("actions %+v", got.Properties.Attachments[0].Actions)
+		}
+	})
+}
+
+func TestActionRouter(t *testing.T) {
+	failure := errors.New("ticket system down")
+	type ctxKey struct{}
+
+	router := mattermost.NewActionRouter()
+	router.Handle("approve", func(ctx context.Context, rq *mattermost.PostActionIntegrationRequest) (*mattermost.PostActionIntegrationResponse, error) {
+		if ctx.Value(ctxKey{}) != "request" {
+			return nil, errors.New("not the request context")
+		}
+		return &mattermost.PostActionIntegrationResponse{
+			Update: &mattermost.Post{Message: "approved by @" + rq.UserName},
+		}, nil
+	})
+	router.Handle("priority", func(_ context.Context, rq *mattermost.PostActionIntegrationRequest) (*mattermost.PostActionIntegrationResponse, error) {
+		return &mattermost.PostActionIntegrationResponse{EphemeralText: "priority " + rq.SelectedOption()}, nil
+	})
+	router.Handle("ignore", func(context.Context, *mattermost.PostActionIntegrationRequest) (*mattermost.PostActionIntegrationResponse, error) {
+		return nil, nil
+	})
+	router.Handle("fail", func(context.Context, *mattermost.PostActionIntegrationRequest) (*mattermost.PostActionIntegrationResponse, error) {
+		return nil, failure
+	})
+	var handled error
+	router.ErrorHandler = func(_ *mattermost.PostActionIntegrationRequest, err error) { handled = err }
+
+	request := func(action string, extra map[string]any) string {
+		ctx := map[string]any{mattermost.PostActionContextKey: action}
+		for k, v := range extra {
+			ctx[k] = v
+		}
+		data, _ := json.Marshal(&mattermost.PostActionIntegrationRequest{UserId: "u1", UserName: "alice", PostId: "p1", Context: ctx})
+		return string(data)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		method      string
+		body        string
+		wantStatus  int
+		wantBody    string
+		wantHandled error
+	}{
+		{name: "update", body: request("approve", nil), wantStatus: http.StatusOK, wantBody: `{"update":{"message":"approved by @alice"}}`},
+		{name: "selected option", body: request("priority", map[string]any{mattermost.PostActionSelectedOption: "high"}), wantStatus: http.StatusOK, wantBody: `{"ephemeral_text":"priority high"}`},
+		{name: "nil response", body: request("ignore", nil), wantStatus: http.StatusOK, wantBody: `{}`},
+		{name: "handler error", body: request("fail", nil), wantStatus: http.StatusInternalServerError, wantHandled: failure},
+		{name: "unknown action", body: request("delete", nil), wantStatus: http.StatusNotFound},
+		{name: "no action", body: `{"user_id":"u1"}`, wantStatus: http.StatusNotFound},
+		{name: "invalid body", body: `{"context":`, wantStatus: http.StatusBadRequest},
+		{name: "body too large", body: `{"user_name":"` + strings.Repeat("a", 2<<20) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handled = nil
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			rq := httptest.NewRequest(method, "/actions", strings.NewReader(tc.body))
+			rq = rq.WithContext(context.WithValue(rq.Context(), ctxKey{}, "request"))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, rq)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if tc.wantBody != "" {
+				if got := strings.TrimSpace(w.Body.String()); !jsonContains(t, got, tc.wantBody) {
+					t.Fatalf("body %s, want %s", got, tc.wantBody)
+				}
+			}
+			if handled != tc.wantHandled {
+				t.Fatalf("error handler got %v, want %v", handled, tc.wantHandled)
+			}
+			if tc.wantStatus == http.StatusMethodNotAllowed && w.Header().Get("Allow") != http.MethodPost {
+				t.Fatalf("Allow %q", w.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+// jsonContains reports whether the JSON object got has the fields of want,
+// ignoring the fields want leaves out.
+func jsonContains(t *testing.T, got, want string) bool {
+	t.Helper()
+	var g, w map[string]any
+	if err := json.Unmarshal([]byte(got), &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	return subset(w, g)
+}
+
+func subset(want, got any) bool {
+	wm, ok := want.(map[string]any)
+	if !ok {
+		return reflect.DeepEqual(want, got)
+	}
+	gm, ok := got.(map[string]any)
+	if !ok || len(wm) == 0 && len(gm) != 0 {
+		return false
+	}
+	for k, v := range wm {
+		if !subset(v, gm[k]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package mattermost
+
+import "sort"
+
+// PostList is a page of posts: Posts holds the posts by id and Order lists
+// their ids, newest first.
+type PostList struct {
+	Order      []string         `json:"order"`
+	Posts      map[string]*Post `json:"posts"`
+	NextPostId string           `json:"next_post_id"`
+	PrevPostId string           `json:"prev_post_id"`
+	// HasNext indicates whether there are more items to be fetched or not.
+	HasNext *bool `json:"has_next,omitempty"`
+	// If there are inaccessible posts, FirstInaccessiblePostTime is the time of the latest inaccessible post
+	FirstInaccessiblePostTime int64 `json:"first_inaccessible_post_time"`
+}
+
+func NewPostList() *PostList {
+	return &PostList{
+		Order: make([]string, 0),
+		Posts: make(map[string]*Post),
+	}
+}
+
+// ToSlice returns the posts in Order. Ids missing from Posts are skipped.
+func (o *PostList) ToSlice() []*Post {
+	posts := make([]*Post, 0, len(o.Order))
+	for _, id := range o.Order {
+		if p, ok := o.Posts[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+	return posts
+}
+
+// SortByCreateAt sorts Order newest first, the order used by the server. Ids
+// missing from Posts are moved to the end, in their original order.
+func (o *PostList) SortByCreateAt() {
+	sort.SliceStable(o.Order, func(i, j int) bool {
+		pi, pj := o.Posts[o.Order[i]], o.Posts[o.Order[j]]
+		if pi == nil || pj == nil {
+			return pj == nil && pi != nil
+		}
+		return pi.CreateAt > pj.CreateAt
+	})
+}
+
+// Thread returns the root post of a thread followed by its replies, oldest
+// first. Posts of other threads are ignored. The root is omitted when it is
+// not in the list.
+func (o *PostList) Thread(rootId string) []*Post {
+	var replies []*Post
+	for _, p := range o.Posts {
+		if p.RootId == rootId {
+			replies = append(replies, p)
+		}
+	}
+	sort.SliceStable(replies, func(i, j int) bool {
+		if replies[i].CreateAt != replies[j].CreateAt {
+			return replies[i].CreateAt < replies[j].CreateAt
+		}
+		return replies[i].Id < replies[j].Id
+	})
+
+	if root, ok := o.Posts[rootId]; ok {
+		return append([]*Post{root}, replies...)
+	}
+	return replies
+}
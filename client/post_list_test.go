@@ -0,0 +1,134 @@
+package mattermost_test
+
+import (
+	"slices"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+// postList returns a list holding posts, in the order given, plus the ids in
+// missing that have no post.
+func postList(posts []*mattermost.Post, missing ...string) *mattermost.PostList {
+	list := mattermost.NewPostList()
+	for _, p := range posts {
+		list.Posts[p.Id] = p
+		list.Order = append(list.Order, p.Id)
+	}
+	list.Order = append(list.Order, missing...)
+	return list
+}
+
+func ids(posts []*mattermost.Post) []string {
+	var res []string
+	for _, p := range posts {
+		res = append(res, p.Id)
+	}
+	return res
+}
+
+func TestPostList(t *testing.T) {
+	root := &mattermost.Post{Id: "root", CreateAt: 1}
+	a := &mattermost.Post{Id: "a", RootId: "root", CreateAt: 3}
+	b := &mattermost.Post{Id: "b", RootId: "root", CreateAt: 2}
+	c := &mattermost.Post{Id: "c", RootId: "root", CreateAt: 2}
+	other := &mattermost.Post{Id: "other", CreateAt: 4}
+
+	for _, tc := range []struct {
+		name       string
+		list       *mattermost.PostList
+		wantSorted []string
+		wantSlice  []string
+		wantThread []string
+	}{
+		{
+			name:       "empty",
+			list:       mattermost.NewPostList(),
+			wantSorted: []string{},
+		},
+		{
+			name:       "thread",
+			list:       postList([]*mattermost.Post{root, b, a, other, c}),
+			wantSorted: []string{"other", "a", "b", "c", "root"},
+			wantSlice:  []string{"other", "a", "b", "c", "root"},
+			wantThread: []string{"root", "b", "c", "a"},
+		},
+		{
+			name:       "ids missing from posts",
+			list:       postList([]*mattermost.Post{root, a}, "gone", "lost"),
+			wantSorted: []string{"a", "root", "gone", "lost"},
+			wantSlice:  []string{"a", "root"},
+			wantThread: []string{"root", "a"},
+		},
+		{
+			name:       "root missing",
+			list:       postList([]*mattermost.Post{c, b}, "root"),
+			wantSorted: []string{"c", "b", "root"},
+			wantSlice:  []string{"c", "b"},
+			wantThread: []string{"b", "c"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.list.SortByCreateAt()
+			if !slices.Equal(tc.list.Order, tc.wantSorted) {
+				t.Fatalf("order %v, want %v", tc.list.Order, tc.wantSorted)
+			}
+			if got := ids(tc.list.ToSlice()); !slices.Equal(got, tc.wantSlice) {
+				t.Fatalf("slice %v, want %v", got, tc.wantSlice)
+			}
+			if got := ids(tc.list.Thread("root")); !slices.Equal(got, tc.wantThread) {
+				t.Fatalf("thread %v, want %v", got, tc.wantThread)
+			}
+		})
+	}
+}
+
+func TestPostAPI(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	c := s.Client(alice.Id)
+
+	root, _, err := c.CreatePost(&mattermost.Post{ChannelId: ch.Id, Message: "root", Properties: mattermost.MsgProperties{Attachments: []mattermost.MsgAttachment{{Text: "keep me"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, _, err := c.CreateSimpleMessagePost(ch.Id, "reply", root.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("get by ids skips unknown posts", func(t *testing.T) {
+		posts, _, err := c.GetPostsByIds([]string{root.Id, mattermosttest.NewId(), reply.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(posts); !slices.Equal(got, []string{root.Id, reply.Id}) {
+			t.Fatalf("posts %v", got)
+		}
+	})
+
+	t.Run("patch leaves unset fields alone", func(t *testing.T) {
+		message, pinned := "edited", true
+		p, _, err := c.PatchPost(root.Id, &mattermost.PostPatch{Message: &message, IsPinned: &pinned})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Message != "edited" || !p.IsPinned || p.EditAt == 0 || len(p.Properties.Attachments) != 1 || p.Properties.Attachments[0].Text != "keep me" {
+			t.Fatalf("patched %+v", p)
+		}
+	})
+
+	t.Run("thread", func(t *testing.T) {
+		list, _, err := c.GetPostThread(reply.Id, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(list.Thread(root.Id)); !slices.Equal(got, []string{root.Id, reply.Id}) {
+			t.Fatalf("thread %v", got)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package mattermost_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestMsgAttachmentFieldDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		json    string
+		want    mattermost.MsgAttachmentField
+		wantErr bool
+	}{
+		{name: "empty", json: `{}`},
+		{name: "strings", json: `{"title":"Status","value":"ok","short":true}`, want: mattermost.MsgAttachmentField{Title: "Status", Value: "ok", Short: true}},
+		{name: "short as a string", json: `{"short":"true"}`, want: mattermost.MsgAttachmentField{Short: true}},
+		{name: "short false as a string", json: `{"short":"false"}`},
+		{name: "integer value", json: `{"value":42}`, want: mattermost.MsgAttachmentField{Value: "42"}},
+		{name: "float value", json: `{"value":-1.5e3}`, want: mattermost.MsgAttachmentField{Value: "-1.5e3"}},
+		{name: "bool value", json: `{"value":true}`, want: mattermost.MsgAttachmentField{Value: "true"}},
+		{name: "null value", json: `{"value":null}`},
+		{name: "escaped value", json: `{"value":"a \"quote\"\n"}`, want: mattermost.MsgAttachmentField{Value: "a \"quote\"\n"}},
+		{name: "invalid short", json: `{"short":1}`, wantErr: true},
+		{name: "invalid title", json: `{"title":1}`, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got mattermost.MsgAttachmentField
+			err := json.Unmarshal([]byte(tc.json), &got)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error %v, want error %v", err, tc.wantErr)
+			}
+			if err == nil && got != tc.want {
+				t.Fatalf("decoded %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMsgAttachmentRoundTrip(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	c := s.Client(alice.Id)
+
+	attachment := mattermost.MsgAttachment{
+		Id:         1,
+		Fallback:   "Build 42 failed",
+		Color:      "#ff0000",
+		Pretext:    "CI",
+		Author:     "ci-bot",
+		AuthorLink: "https://ci.example.com",
+		AuthorIcon: "https://ci.example.com/icon.png",
+		Title:      "Build 42",
+		TitleLink:  "https://ci.example.com/42",
+		Text:       "failed",
+		Fields: []mattermost.MsgAttachmentField{
+			{Title: "Branch", Value: "main", Short: true},
+			{Title: "Duration", Value: "90", Short: false},
+		},
+		ImageURL:   "https://ci.example.com/42.png",
+		ThumbUrl:   "https://ci.example.com/42-thumb.png",
+		Footer:     "ci",
+		FooterIcon: "https://ci.example.com/footer.png",
+		Timestamp:  "1700000000",
+	}
+	created, _, err := c.CreatePost(&mattermost.Post{
+		ChannelId:  ch.Id,
+		Properties: mattermost.MsgProperties{Attachments: []mattermost.MsgAttachment{attachment}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := c.GetPost(created.Id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Properties.Attachments) != 1 || !reflect.DeepEqual(got.Properties.Attachments[0], attachment) {
+		t.Fatalf("attachments %+v, want %+v", got.Properties.Attachments, attachment)
+	}
+
+	// Posts written by Slack compatible integrations use numbers and strings
+	// where the model has other types.
+	var p mattermost.Post
+	legacy := `{"props":{"attachments":[{"ts":1700000000,"fields":[{"title":"Count","value":3,"short":"true"}]}]}}`
+	if err := json.Unmarshal([]byte(legacy), &p); err != nil {
+		t.Fatal(err)
+	}
+	a := p.Properties.Attachments[0]
+	if want := (mattermost.MsgAttachmentField{Title: "Count", Value: "3", Short: true}); a.Fields[0] != want {
+		t.Fatalf("field %+v, want %+v", a.Fields[0], want)
+	}
+	if a.Timestamp != float64(1700000000) {
+		t.Fatalf("timestamp %#v", a.Timestamp)
+	}
+}
@@ -0,0 +1,130 @@
+package mattermost
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by every request made through a Client4.
+// Requests block until a token is available instead of failing with 429. The
+// bucket adapts to the X-RateLimit-* headers returned by the server, so it also
+// accounts for requests made by other clients using the same credentials.
+// It is safe for concurrent use.
+type RateLimiter struct {
+	mu          sync.Mutex
+	perSecond   float64 // Configured rate, 0 when only the server's rate applies
+	serverRate  float64 // Rate derived from the X-RateLimit-* headers, 0 until known
+	burst       float64 // Maximum number of tokens
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time // Set when the server reports the limit exhausted
+}
+
+// NewRateLimiter returns a limiter allowing perSecond requests on average with
+// bursts of up to burst requests. A perSecond of zero or less sets no rate of
+// its own: requests are then only slowed down to the rate the server reports.
+// When both are known, the lower one applies.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		perSecond: max(perSecond, 0),
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+}
+
+// rate returns the tokens added per second, or 0 when no rate is known.
+func (l *RateLimiter) rate() float64 {
+	switch {
+	case l.perSecond <= 0:
+		return l.serverRate
+	case l.serverRate > 0:
+		return min(l.perSecond, l.serverRate)
+	default:
+		return l.perSecond
+	}
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if rate := l.rate(); rate > 0 {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*rate)
+	} else {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+
+		var delay time.Duration
+		switch {
+		case now.Before(l.pausedUntil):
+			delay = l.pausedUntil.Sub(now)
+		case l.tokens >= 1:
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		default:
+			delay = time.Duration((1 - l.tokens) / l.rate() * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Observe adjusts the bucket to the rate limit state reported by the server.
+// X-RateLimit-Limit sets the burst. When the limit is exhausted, the server
+// needs X-RateLimit-Reset seconds to give back X-RateLimit-Limit requests,
+// which sets the rate.
+func (l *RateLimiter) Observe(r *Response) {
+	if r == nil || r.Header == nil {
+		return
+	}
+
+	limit, limitErr := strconv.Atoi(r.Header.Get(HeaderRateLimitLimit))
+	remaining, remainingErr := strconv.Atoi(r.Header.Get(HeaderRateLimitRemaining))
+	reset, resetErr := strconv.Atoi(r.Header.Get(HeaderRateLimitReset))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	if limitErr == nil && limit > 0 {
+		l.burst = float64(limit)
+		l.tokens = min(l.tokens, l.burst)
+	}
+	if remainingErr == nil && remaining >= 0 {
+		l.tokens = min(l.tokens, float64(remaining))
+	}
+
+	exhausted := r.StatusCode == http.StatusTooManyRequests || (remainingErr == nil && remaining == 0)
+	if !exhausted {
+		return
+	}
+	if limitErr == nil && limit > 0 && resetErr == nil && reset > 0 {
+		l.serverRate = float64(limit) / float64(reset)
+	}
+	wait, ok := retryAfterDelay(r.Header)
+	if !ok {
+		wait, _ = rateLimitResetDelay(r.Header)
+	}
+	if until := now.Add(wait); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
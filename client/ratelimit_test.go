@@ -0,0 +1,153 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		perSecond  float64
+		burst      int
+		waits      int
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		{name: "burst is not delayed", perSecond: 10, burst: 5, waits: 5, maxElapsed: 50 * time.Millisecond},
+		{name: "refills at the configured rate", perSecond: 100, burst: 2, waits: 5, minElapsed: 25 * time.Millisecond, maxElapsed: time.Second},
+		{name: "no rate does not block", perSecond: 0, burst: 1, waits: 100, maxElapsed: 50 * time.Millisecond},
+		{name: "negative rate does not block", perSecond: -1, burst: 1, waits: 100, maxElapsed: 50 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := mattermost.NewRateLimiter(tc.perSecond, tc.burst)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			start := time.Now()
+			for i := 0; i < tc.waits; i++ {
+				if err := l.Wait(ctx); err != nil {
+					t.Fatalf("wait %d: %v", i+1, err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tc.minElapsed || elapsed > tc.maxElapsed {
+				t.Fatalf("elapsed %v, want between %v and %v", elapsed, tc.minElapsed, tc.maxElapsed)
+			}
+		})
+	}
+}
+
+func rateLimitResponse(status, limit, remaining, reset int) *mattermost.Response {
+	h := http.Header{}
+	h.Set(mattermost.HeaderRateLimitLimit, strconv.Itoa(limit))
+	h.Set(mattermost.HeaderRateLimitRemaining, strconv.Itoa(remaining))
+	h.Set(mattermost.HeaderRateLimitReset, strconv.Itoa(reset))
+	return &mattermost.Response{StatusCode: status, Header: h}
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		perSecond float64
+		observe   *mattermost.Response
+		// Waits that must succeed immediately, then whether one more blocks.
+		immediate int
+		blocks    bool
+	}{
+		{name: "remaining lowers the tokens", perSecond: 1, observe: rateLimitResponse(http.StatusOK, 10, 2, 1), immediate: 2, blocks: true},
+		{name: "limit caps the burst", perSecond: 1, observe: rateLimitResponse(http.StatusOK, 3, 3, 0), immediate: 3, blocks: true},
+		{name: "exhausted limit pauses", perSecond: 1000, observe: rateLimitResponse(http.StatusOK, 10, 0, 1), immediate: 0, blocks: true},
+		{name: "429 pauses without a rate", perSecond: 0, observe: rateLimitResponse(http.StatusTooManyRequests, 10, 0, 1), immediate: 0, blocks: true},
+		{name: "headers without exhaustion do not block without a rate", perSecond: 0, observe: rateLimitResponse(http.StatusOK, 10, 1, 1), immediate: 20, blocks: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := mattermost.NewRateLimiter(tc.perSecond, 50)
+			l.Observe(tc.observe)
+			for i := 0; i < tc.immediate; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				err := l.Wait(ctx)
+				cancel()
+				if err != nil {
+					t.Fatalf("wait %d: %v", i+1, err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := l.Wait(ctx)
+			if blocked := errors.Is(err, context.DeadlineExceeded); blocked != tc.blocks {
+				t.Fatalf("blocked = %v, want %v", blocked, tc.blocks)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAdaptsToServerRate(t *testing.T) {
+	l := mattermost.NewRateLimiter(0, 1)
+	// The server needs 1s to give back 20 requests: 20 per second.
+	l.Observe(rateLimitResponse(http.StatusOK, 20, 0, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// After the pause the bucket is full again, then refills at 20 per second.
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("elapsed %v", elapsed)
+	}
+}
+
+func TestRateLimiterAgainstServer(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		limiter *mattermost.RateLimiter
+		retry   *mattermost.RetryPolicy
+	}{
+		{name: "configured rate below the server limit", limiter: mattermost.NewRateLimiter(10, 5)},
+		{name: "learns the server limit", limiter: mattermost.NewRateLimiter(0, 1), retry: &mattermost.RetryPolicy{MaxAttempts: 3, MaxBackoff: 2 * time.Second}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := mattermosttest.NewServer()
+			defer s.Close()
+			u := s.AddUser(&mattermost.User{Username: "limited"}, "password")
+			s.SetRateLimit(20)
+
+			c := s.Client(u.Id)
+			c.RateLimiter = tc.limiter
+			c.RetryPolicy = tc.retry
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			var wg sync.WaitGroup
+			errs := make(chan error, 30)
+			for i := 0; i < 30; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _, err := c.GetMeContext(ctx, "")
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
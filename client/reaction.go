@@ -0,0 +1,101 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type Reaction struct {
+	UserId    string  `json:"user_id"`
+	PostId    string  `json:"post_id"`
+	EmojiName string  `json:"emoji_name"`
+	CreateAt  int64   `json:"create_at"`
+	UpdateAt  int64   `json:"update_at"`
+	DeleteAt  int64   `json:"delete_at"`
+	RemoteId  *string `json:"remote_id"`
+	ChannelId string  `json:"channel_id"`
+}
+
+// SaveReaction adds an emoji reaction to a post. The emoji name may be given
+// with or without colons, as in "eyes" or ":eyes:". Reacting twice with the
+// same emoji is not an error.
+func (c *Client4) SaveReaction(reaction *Reaction) (*Reaction, *Response, error) {
+	return c.SaveReactionContext(context.Background(), reaction)
+}
+
+func (c *Client4) SaveReactionContext(ctx context.Context, reaction *Reaction) (*Reaction, *Response, error) {
+	rc := *reaction
+	rc.EmojiName = normalizeEmojiName(rc.EmojiName)
+	buf, err := json.Marshal(&rc)
+	if err != nil {
+		return nil, nil, NewAppError("SaveReaction", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	r, err := c.DoAPIPostContext(ctx, c.reactionsRoute(), string(buf))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var res Reaction
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return nil, nil, NewAppError("SaveReaction", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &res, BuildResponse(r), nil
+}
+
+// DeleteReaction removes the reaction of reaction.UserId with
+// reaction.EmojiName from reaction.PostId.
+func (c *Client4) DeleteReaction(reaction *Reaction) (*Response, error) {
+	return c.DeleteReactionContext(context.Background(), reaction)
+}
+
+func (c *Client4) DeleteReactionContext(ctx context.Context, reaction *Reaction) (*Response, error) {
+	r, err := c.DoAPIDeleteContext(ctx, c.userPostReactionRoute(reaction.UserId, reaction.PostId, normalizeEmojiName(reaction.EmojiName)))
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
+// GetReactions gets the reactions to a post.
+func (c *Client4) GetReactions(postId string) ([]*Reaction, *Response, error) {
+	return c.GetReactionsContext(context.Background(), postId)
+}
+
+func (c *Client4) GetReactionsContext(ctx context.Context, postId string) ([]*Reaction, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.postReactionsRoute(postId), "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var list []*Reaction
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, nil, NewAppError("GetReactions", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return list, BuildResponse(r), nil
+}
+
+// GetReactionsForPosts gets the reactions to several posts in one request,
+// keyed by post id. Posts without reactions map to an empty slice.
+func (c *Client4) GetReactionsForPosts(postIds []string) (map[string][]*Reaction, *Response, error) {
+	return c.GetReactionsForPostsContext(context.Background(), postIds)
+}
+
+func (c *Client4) GetReactionsForPostsContext(ctx context.Context, postIds []string) (map[string][]*Reaction, *Response, error) {
+	r, err := c.DoAPIPostContext(ctx, c.postsRoute()+"/ids/reactions", ArrayToJSON(postIds))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	reactions := map[string][]*Reaction{}
+	if err := json.NewDecoder(r.Body).Decode(&reactions); err != nil {
+		return nil, nil, NewAppError("GetReactionsForPosts", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return reactions, BuildResponse(r), nil
+}
+
+func normalizeEmojiName(name string) string {
+	return strings.Trim(strings.TrimSpace(name), ":")
+}
@@ -0,0 +1,108 @@
+package mattermost_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func emojiNames(list []*mattermost.Reaction) []string {
+	var res []string
+	for _, r := range list {
+		res = append(res, r.UserId+" "+r.EmojiName)
+	}
+	slices.Sort(res)
+	return res
+}
+
+func TestReactions(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	private := s.AddChannel(&mattermost.Channel{Name: "private", Type: mattermost.ChannelTypePrivate})
+	s.AddChannelMember(ch.Id, alice.Id)
+	s.AddChannelMember(ch.Id, bob.Id)
+	s.AddChannelMember(private.Id, bob.Id)
+	c := s.Client(alice.Id)
+	post, _, err := c.CreateSimpleMessagePost(ch.Id, "hello", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, _, err := s.Client(bob.Id).CreateSimpleMessagePost(private.Id, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		reaction *mattermost.Reaction
+		want     []string
+		wantErr  error
+	}{
+		{name: "plain name", reaction: &mattermost.Reaction{UserId: alice.Id, PostId: post.Id, EmojiName: "eyes"}, want: []string{alice.Id + " eyes"}},
+		{name: "colons", reaction: &mattermost.Reaction{UserId: alice.Id, PostId: post.Id, EmojiName: " :+1: "}, want: []string{alice.Id + " +1", alice.Id + " eyes"}},
+		{name: "twice", reaction: &mattermost.Reaction{UserId: alice.Id, PostId: post.Id, EmojiName: ":eyes:"}, want: []string{alice.Id + " +1", alice.Id + " eyes"}},
+		{name: "other user", reaction: &mattermost.Reaction{UserId: bob.Id, PostId: post.Id, EmojiName: "eyes"}, wantErr: mattermost.ErrForbidden},
+		{name: "unreadable post", reaction: &mattermost.Reaction{UserId: alice.Id, PostId: hidden.Id, EmojiName: "eyes"}, wantErr: mattermost.ErrNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			saved, _, err := c.SaveReaction(tc.reaction)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.ChannelId != ch.Id || saved.CreateAt == 0 {
+				t.Fatalf("saved %+v", saved)
+			}
+			list, _, err := c.GetReactions(post.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := emojiNames(list); !slices.Equal(got, tc.want) {
+				t.Fatalf("reactions %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		if _, err := c.DeleteReaction(&mattermost.Reaction{UserId: alice.Id, PostId: post.Id, EmojiName: ":+1:"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := emojiNames(s.Reactions(post.Id)); !slices.Equal(got, []string{alice.Id + " eyes"}) {
+			t.Fatalf("reactions %v", got)
+		}
+		if _, err := c.DeleteReaction(&mattermost.Reaction{UserId: bob.Id, PostId: post.Id, EmojiName: "eyes"}); !errors.Is(err, mattermost.ErrForbidden) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+
+	t.Run("for posts", func(t *testing.T) {
+		quiet, _, err := c.CreateSimpleMessagePost(ch.Id, "no reactions", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reactions, _, err := c.GetReactionsForPosts([]string{post.Id, quiet.Id, hidden.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := emojiNames(reactions[post.Id]); !slices.Equal(got, []string{alice.Id + " eyes"}) {
+			t.Fatalf("reactions %v", got)
+		}
+		if list, ok := reactions[quiet.Id]; !ok || len(list) != 0 {
+			t.Fatalf("reactions to a post without any: %v, %v", list, ok)
+		}
+		if _, ok := reactions[hidden.Id]; ok {
+			t.Fatal("reactions to an unreadable post")
+		}
+	})
+}
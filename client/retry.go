@@ -0,0 +1,233 @@
+package mattermost
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryMinBackoff  = 500 * time.Millisecond
+	DefaultRetryMaxBackoff  = 30 * time.Second
+)
+
+// RetryPolicy controls how Client4 retries requests that failed with a network
+// error or a transient status code (429, 502, 503, 504).
+type RetryPolicy struct {
+	MaxAttempts int           // Total number of attempts, including the first one
+	MinBackoff  time.Duration // Delay before the first retry
+	MaxBackoff  time.Duration // Upper bound for the computed exponential delay
+
+	// MaxRetryAfter is the longest delay requested by the server through
+	// Retry-After or X-RateLimit-Reset that is waited for; when the server asks
+	// for more, the response is returned instead. Zero means MaxBackoff.
+	MaxRetryAfter time.Duration
+
+	// RetryNonIdempotent allows POST and PATCH requests to be retried as well.
+	// Only enable it when the endpoints being called tolerate duplicates.
+	RetryNonIdempotent bool
+}
+
+// NewRetryPolicy returns a policy with the package defaults.
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultRetryMaxAttempts,
+		MinBackoff:  DefaultRetryMinBackoff,
+		MaxBackoff:  DefaultRetryMaxBackoff,
+	}
+}
+
+func (p *RetryPolicy) canRetryMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return p.RetryNonIdempotent
+}
+
+func (p *RetryPolicy) shouldRetry(rp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch rp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry, starting at 1. A delay
+// requested by the server through Retry-After or X-RateLimit-Reset takes
+// precedence over the exponential delay; backoff reports false when that delay
+// exceeds MaxRetryAfter, in which case the request should not be retried.
+func (p *RetryPolicy) backoff(retry int, rp *http.Response) (time.Duration, bool) {
+	if d, ok := serverRetryDelay(rp); ok {
+		limit := p.MaxRetryAfter
+		if limit <= 0 {
+			limit = p.MaxBackoff
+		}
+		return d, d <= limit
+	}
+
+	d := p.MinBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0, true
+	}
+
+	// Equal jitter: keep half of the delay and randomize the other half so that
+	// goroutines sharing a client do not retry in lockstep.
+	half := d / 2
+	return half + rand.N(d-half+1), true
+}
+
+func serverRetryDelay(rp *http.Response) (time.Duration, bool) {
+	if rp == nil {
+		return 0, false
+	}
+	if d, ok := retryAfterDelay(rp.Header); ok {
+		return d, true
+	}
+	if rp.StatusCode == http.StatusTooManyRequests {
+		return rateLimitResetDelay(rp.Header)
+	}
+	return 0, false
+}
+
+func retryAfterDelay(h http.Header) (time.Duration, bool) {
+	v := h.Get(HeaderRetryAfter)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// rateLimitResetDelay reads X-RateLimit-Reset, which Mattermost sends as the
+// number of seconds until the limit resets.
+func rateLimitResetDelay(h http.Header) (time.Duration, bool) {
+	secs, err := strconv.Atoi(h.Get(HeaderRateLimitReset))
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// rewindBody prepares rq to be sent again. It reports false when the body has
+// already been consumed and cannot be recreated.
+func rewindBody(rq *http.Request) (*http.Request, bool) {
+	if rq.Body == nil || rq.Body == http.NoBody {
+		return rq, true
+	}
+	if rq.GetBody == nil {
+		return nil, false
+	}
+	body, err := rq.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	next := rq.Clone(rq.Context())
+	next.Body = body
+	return next, true
+}
+
+// rewindableBody returns the body to send for data and, when data can be read
+// again, a GetBody function creating a fresh reader for each retry. Only
+// readers implementing io.ReaderAt and io.Seeker, such as *os.File,
+// *bytes.Reader or *io.SectionReader, are rewindable: each attempt reads its
+// own section from the offset data was at, so an attempt still being closed by
+// the transport never shares a read position with the next one. data itself
+// is neither moved nor closed. Requests with other readers are not retried.
+func rewindableBody(data io.Reader) (io.Reader, int64, func() (io.ReadCloser, error)) {
+	ra, ok := data.(io.ReaderAt)
+	seeker, ok2 := data.(io.Seeker)
+	if !ok || !ok2 {
+		return data, -1, nil
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return data, -1, nil
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if _, serr := seeker.Seek(offset, io.SeekStart); err != nil || serr != nil || size < offset {
+		return data, -1, nil
+	}
+	getBody := func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(ra, offset, size-offset)), nil
+	}
+	body, _ := getBody()
+	return body, size - offset, getBody
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type noRetryKey struct{}
+
+// withoutRetries returns a context whose requests are sent once, for methods
+// retrying a sequence of requests with a single count of attempts.
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// doRequest sends rq, retrying it according to c.RetryPolicy.
+func (c *Client4) doRequest(rq *http.Request) (*http.Response, error) {
+	policy := c.RetryPolicy
+	if policy == nil || policy.MaxAttempts <= 1 || !policy.canRetryMethod(rq.Method) || rq.Context().Value(noRetryKey{}) != nil {
+		return c.send(rq)
+	}
+
+	ctx := rq.Context()
+	for attempt := 1; ; attempt++ {
+		rp, err := c.send(rq)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(rp, err) {
+			return rp, err
+		}
+
+		next, ok := rewindBody(rq)
+		if !ok {
+			return rp, err
+		}
+
+		delay, ok := policy.backoff(attempt, rp)
+		if !ok {
+			return rp, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return rp, err
+		}
+		if rp != nil {
+			closeBody(rp)
+		}
+		if serr := sleepContext(ctx, delay); serr != nil {
+			return nil, serr
+		}
+		rq = next
+	}
+}
@@ -0,0 +1,183 @@
+package mattermost
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type scriptedResponse struct {
+	status int
+	header map[string]string
+}
+
+// scriptedServer answers requests with responses in order, then with 200, and
+// records the bodies it received.
+type scriptedServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses []scriptedResponse
+	bodies    []string
+}
+
+func newScriptedServer(t *testing.T, responses ...scriptedResponse) *scriptedServer {
+	s := &scriptedServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		rp := scriptedResponse{status: http.StatusOK}
+		if len(s.responses) > 0 {
+			rp, s.responses = s.responses[0], s.responses[1:]
+		}
+		s.mu.Unlock()
+		for k, v := range rp.header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(rp.status)
+		_, _ = w.Write([]byte(`{"status":"OK"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	unavailable := scriptedResponse{status: http.StatusServiceUnavailable}
+	for _, tc := range []struct {
+		name          string
+		method        string
+		nonIdempotent bool
+		responses     []scriptedResponse
+		wantAttempts  int
+		wantErr       error
+	}{
+		{name: "retries until success", method: http.MethodGet, responses: []scriptedResponse{unavailable, unavailable}, wantAttempts: 3},
+		{name: "gives up after MaxAttempts", method: http.MethodGet, responses: []scriptedResponse{unavailable, unavailable, unavailable}, wantAttempts: 3, wantErr: ErrServerUnavailable},
+		{name: "honours Retry-After", method: http.MethodGet, responses: []scriptedResponse{{status: http.StatusTooManyRequests, header: map[string]string{HeaderRetryAfter: "0"}}}, wantAttempts: 2},
+		{name: "does not wait for a long Retry-After", method: http.MethodGet, responses: []scriptedResponse{{status: http.StatusTooManyRequests, header: map[string]string{HeaderRetryAfter: "3600"}}}, wantAttempts: 1, wantErr: ErrRateLimited},
+		{name: "does not wait for a long rate limit reset", method: http.MethodGet, responses: []scriptedResponse{{status: http.StatusTooManyRequests, header: map[string]string{HeaderRateLimitReset: "3600"}}}, wantAttempts: 1, wantErr: ErrRateLimited},
+		{name: "does not retry client errors", method: http.MethodGet, responses: []scriptedResponse{{status: http.StatusNotFound}}, wantAttempts: 1, wantErr: ErrNotFound},
+		{name: "does not retry POST", method: http.MethodPost, responses: []scriptedResponse{unavailable}, wantAttempts: 1, wantErr: ErrServerUnavailable},
+		{name: "retries POST when allowed", method: http.MethodPost, nonIdempotent: true, responses: []scriptedResponse{unavailable}, wantAttempts: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newScriptedServer(t, tc.responses...)
+			c := NewAPIv4Client(s.URL)
+			c.RetryPolicy = testRetryPolicy()
+			c.RetryPolicy.RetryNonIdempotent = tc.nonIdempotent
+
+			start := time.Now()
+			r, err := c.DoAPIRequest(tc.method, s.URL+"/api/v4/test", "body", "")
+			if r != nil {
+				closeBody(r)
+			}
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if got := s.attempts(); got != tc.wantAttempts {
+				t.Fatalf("attempts = %d, want %d", got, tc.wantAttempts)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("took %v", elapsed)
+			}
+			for i, body := range s.bodies {
+				if body != "body" {
+					t.Fatalf("body of attempt %d = %q", i+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryRewindsFileBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(path, []byte("skip:file content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(int64(len("skip:")), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newScriptedServer(t, scriptedResponse{status: http.StatusBadGateway})
+	c := NewAPIv4Client(s.URL)
+	c.RetryPolicy = testRetryPolicy()
+	r, err := c.DoAPIRequestReader(http.MethodPut, s.URL+"/api/v4/test", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeBody(r)
+
+	if len(s.bodies) != 2 || s.bodies[0] != "file content" || s.bodies[1] != "file content" {
+		t.Fatalf("bodies = %q", s.bodies)
+	}
+	// The caller still owns the file, at its original offset.
+	rest, err := io.ReadAll(f)
+	if err != nil || string(rest) != "file content" {
+		t.Fatalf("file after request: %q, %v", rest, err)
+	}
+}
+
+func TestRetryDoesNotReplayStreams(t *testing.T) {
+	s := newScriptedServer(t, scriptedResponse{status: http.StatusServiceUnavailable})
+	c := NewAPIv4Client(s.URL)
+	c.RetryPolicy = testRetryPolicy()
+	body := io.MultiReader(strings.NewReader("stream"))
+	_, err := c.DoAPIRequestReader(http.MethodPut, s.URL+"/api/v4/test", body, nil)
+	if !errors.Is(err, ErrServerUnavailable) || s.attempts() != 1 {
+		t.Fatalf("err = %v after %d attempts", err, s.attempts())
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 10, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry := 1; retry <= 8; retry++ {
+		d, ok := p.backoff(retry, nil)
+		if !ok || d < p.MinBackoff/2 || d > p.MaxBackoff {
+			t.Fatalf("backoff(%d) = %v, %v", retry, d, ok)
+		}
+	}
+
+	for _, tc := range []struct {
+		name          string
+		maxRetryAfter time.Duration
+		retryAfter    string
+		want          time.Duration
+		wantOK        bool
+	}{
+		{name: "within MaxBackoff", retryAfter: "1", want: time.Second, wantOK: true},
+		{name: "beyond MaxBackoff", retryAfter: "2", want: 2 * time.Second, wantOK: false},
+		{name: "within MaxRetryAfter", maxRetryAfter: time.Minute, retryAfter: "30", want: 30 * time.Second, wantOK: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := *p
+			p.MaxRetryAfter = tc.maxRetryAfter
+			rp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{HeaderRetryAfter: {tc.retryAfter}}}
+			d, ok := p.backoff(1, rp)
+			if d != tc.want || ok != tc.wantOK {
+				t.Fatalf("backoff = %v, %v, want %v, %v", d, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
@@ -8,6 +8,15 @@ func (c *Client4) postsRoute() string {
 func (c *Client4) postRoute(postId string) string {
 	return fmt.Sprintf(c.postsRoute()+"/%v", postId)
 }
+func (c *Client4) reactionsRoute() string {
+	return "/reactions"
+}
+func (c *Client4) postReactionsRoute(postId string) string {
+	return c.postRoute(postId) + "/reactions"
+}
+func (c *Client4) userPostReactionRoute(userId, postId, emojiName string) string {
+	return c.userRoute(userId) + c.postReactionsRoute(postId) + "/" + emojiName
+}
 func (c *Client4) usersRoute() string {
 	return "/users"
 }
@@ -23,12 +32,30 @@ func (c *Client4) channelsRoute() string {
 func (c *Client4) channelMemberRoute(channelId, userId string) string {
 	return fmt.Sprintf(c.channelMembersRoute(channelId)+"/%v", userId)
 }
+func (c *Client4) channelPostsRoute(channelId string) string {
+	return c.channelRoute(channelId) + "/posts"
+}
+func (c *Client4) userLoginRoute() string {
+	return c.usersRoute() + "/login"
+}
+func (c *Client4) userLogoutRoute() string {
+	return c.usersRoute() + "/logout"
+}
+func (c *Client4) userAccessTokensRoute() string {
+	return c.usersRoute() + "/tokens"
+}
+func (c *Client4) userAccessTokenRoute(tokenId string) string {
+	return fmt.Sprintf(c.usersRoute()+"/tokens/%v", tokenId)
+}
 func (c *Client4) userRoute(userId string) string {
 	return fmt.Sprintf(c.usersRoute()+"/%v", userId)
 }
 func (c *Client4) teamRoute(teamId string) string {
 	return fmt.Sprintf(c.teamsRoute()+"/%v", teamId)
 }
+func (c *Client4) teamPostsSearchRoute(teamId string) string {
+	return c.teamRoute(teamId) + "/posts/search"
+}
 func (c *Client4) teamsRoute() string {
 	return "/teams"
 }
@@ -50,3 +77,27 @@ func (c *Client4) userThreadsRoute(userID, teamID string) string {
 func (c *Client4) userThreadRoute(userId, teamId, threadId string) string {
 	return c.userThreadsRoute(userId, teamId) + "/" + threadId
 }
+
+func (c *Client4) oAuthAppsRoute() string {
+	return "/oauth/apps"
+}
+
+func (c *Client4) oAuthAppRoute(appId string) string {
+	return fmt.Sprintf("/oauth/apps/%v", appId)
+}
+
+func (c *Client4) filesRoute() string {
+	return "/files"
+}
+
+func (c *Client4) fileRoute(fileId string) string {
+	return c.filesRoute() + "/" + fileId
+}
+
+func (c *Client4) uploadsRoute() string {
+	return "/uploads"
+}
+
+func (c *Client4) uploadRoute(uploadId string) string {
+	return c.uploadsRoute() + "/" + uploadId
+}
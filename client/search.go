@@ -0,0 +1,222 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchParameter describes a post search. Terms uses the search syntax of the
+// Mattermost web app; build it with SearchQuery rather than by hand.
+// TimeZoneOffset, in seconds east of UTC, is used to interpret the dates of
+// before:, after: and on:.
+type SearchParameter struct {
+	Terms                  string `json:"terms"`
+	IsOrSearch             bool   `json:"is_or_search"`
+	TimeZoneOffset         int    `json:"time_zone_offset"`
+	Page                   int    `json:"page"`
+	PerPage                int    `json:"per_page,omitempty"` // The server default is 60
+	IncludeDeletedChannels bool   `json:"include_deleted_channels"`
+}
+
+// PostSearchMatches maps post ids to the terms they matched.
+type PostSearchMatches map[string][]string
+
+// PostSearchResults is a PostList ordered by relevance, newest first for
+// equal matches.
+type PostSearchResults struct {
+	*PostList
+	Matches PostSearchMatches `json:"matches"`
+}
+
+// SearchPosts searches the posts of a team the user can read.
+func (c *Client4) SearchPosts(teamId string, terms string, isOrSearch bool) (*PostSearchResults, *Response, error) {
+	return c.SearchPostsContext(context.Background(), teamId, terms, isOrSearch)
+}
+
+func (c *Client4) SearchPostsContext(ctx context.Context, teamId string, terms string, isOrSearch bool) (*PostSearchResults, *Response, error) {
+	return c.SearchPostsWithParamsContext(ctx, teamId, &SearchParameter{Terms: terms, IsOrSearch: isOrSearch})
+}
+
+// SearchPostsWithParams searches the posts of a team with every search option.
+func (c *Client4) SearchPostsWithParams(teamId string, params *SearchParameter) (*PostSearchResults, *Response, error) {
+	return c.SearchPostsWithParamsContext(context.Background(), teamId, params)
+}
+
+func (c *Client4) SearchPostsWithParamsContext(ctx context.Context, teamId string, params *SearchParameter) (*PostSearchResults, *Response, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, NewAppError("SearchPostsWithParams", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	r, err := c.DoAPIPostContext(ctx, c.teamPostsSearchRoute(teamId), string(paramsJSON))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	results := &PostSearchResults{PostList: NewPostList()}
+	if err := json.NewDecoder(r.Body).Decode(results); err != nil {
+		return nil, nil, NewAppError("SearchPostsWithParams", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return results, BuildResponse(r), nil
+}
+
+// searchDateFormat is the date layout of the before:, after: and on: filters.
+const searchDateFormat = "2006-01-02"
+
+// SearchQuery builds the terms of a post search, quoting and prefixing values
+// as the server expects:
+//
+//	terms := mattermost.NewSearchQuery().
+//		Words("deploy", "failed").
+//		From("ci-bot").
+//		In("incidents").
+//		After(time.Now().AddDate(0, 0, -7)).
+//		Exclude("staging").
+//		String()
+//
+// Dates are formatted in their own location; pass the matching offset in
+// SearchParameter.TimeZoneOffset. Usernames, channel names and hashtags cannot
+// contain spaces: such values are left out and reported by Err, which should be
+// checked before searching as the query would otherwise match more posts.
+type SearchQuery struct {
+	terms []string
+	err   error
+}
+
+func NewSearchQuery() *SearchQuery {
+	return &SearchQuery{}
+}
+
+// Words adds terms to match. Words containing spaces are searched as phrases,
+// as are words the server would read as a filter, an exclusion or a hashtag.
+func (q *SearchQuery) Words(words ...string) *SearchQuery {
+	for _, w := range words {
+		q.add("", searchTerm(w))
+	}
+	return q
+}
+
+// Phrase adds an exact phrase to match.
+func (q *SearchQuery) Phrase(phrase string) *SearchQuery {
+	q.add("", quoteSearchTerm(phrase))
+	return q
+}
+
+// Hashtags adds hashtags to match, with or without their leading #.
+func (q *SearchQuery) Hashtags(tags ...string) *SearchQuery {
+	for _, t := range tags {
+		q.addName("#", strings.TrimPrefix(strings.TrimSpace(t), "#"))
+	}
+	return q
+}
+
+// Exclude adds words or phrases that matching posts must not contain.
+func (q *SearchQuery) Exclude(words ...string) *SearchQuery {
+	for _, w := range words {
+		q.add("-", searchTerm(w))
+	}
+	return q
+}
+
+// From restricts the search to posts by the given usernames.
+func (q *SearchQuery) From(usernames ...string) *SearchQuery {
+	for _, u := range usernames {
+		q.addName("from:", strings.TrimPrefix(strings.TrimSpace(u), "@"))
+	}
+	return q
+}
+
+// ExcludeFrom leaves out posts by the given usernames.
+func (q *SearchQuery) ExcludeFrom(usernames ...string) *SearchQuery {
+	for _, u := range usernames {
+		q.addName("-from:", strings.TrimPrefix(strings.TrimSpace(u), "@"))
+	}
+	return q
+}
+
+// In restricts the search to the channels with the given names, not display
+// names.
+func (q *SearchQuery) In(channelNames ...string) *SearchQuery {
+	for _, ch := range channelNames {
+		q.addName("in:", strings.TrimPrefix(strings.TrimSpace(ch), "~"))
+	}
+	return q
+}
+
+// ExcludeIn leaves out the channels with the given names.
+func (q *SearchQuery) ExcludeIn(channelNames ...string) *SearchQuery {
+	for _, ch := range channelNames {
+		q.addName("-in:", strings.TrimPrefix(strings.TrimSpace(ch), "~"))
+	}
+	return q
+}
+
+// Before restricts the search to posts made before the day of t.
+func (q *SearchQuery) Before(t time.Time) *SearchQuery {
+	q.add("before:", t.Format(searchDateFormat))
+	return q
+}
+
+// After restricts the search to posts made after the day of t.
+func (q *SearchQuery) After(t time.Time) *SearchQuery {
+	q.add("after:", t.Format(searchDateFormat))
+	return q
+}
+
+// On restricts the search to posts made on the day of t.
+func (q *SearchQuery) On(t time.Time) *SearchQuery {
+	q.add("on:", t.Format(searchDateFormat))
+	return q
+}
+
+// String returns the terms for SearchParameter.Terms.
+func (q *SearchQuery) String() string {
+	return strings.Join(q.terms, " ")
+}
+
+// Err returns the first value that was left out of the query because it
+// cannot be searched for.
+func (q *SearchQuery) Err() error {
+	return q.err
+}
+
+func (q *SearchQuery) add(prefix, value string) {
+	if value == "" || value == `""` {
+		return
+	}
+	q.terms = append(q.terms, prefix+value)
+}
+
+// addName adds a filter on a name, which the search syntax cannot quote.
+func (q *SearchQuery) addName(prefix, name string) {
+	if strings.ContainsFunc(name, unicode.IsSpace) || strings.Contains(name, `"`) {
+		if q.err == nil {
+			q.err = fmt.Errorf("mattermost: cannot search for %s%q", prefix, name)
+		}
+		return
+	}
+	q.add(prefix, name)
+}
+
+// searchTerm quotes s when it would not be searched for as a plain word.
+func searchTerm(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.ContainsFunc(s, unicode.IsSpace) || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "#") || strings.Contains(s, ":") {
+		return quoteSearchTerm(s)
+	}
+	return s
+}
+
+// quoteSearchTerm wraps s in double quotes. The syntax has no escape sequence,
+// so quotes inside s are dropped.
+func quoteSearchTerm(s string) string {
+	s = strings.TrimSpace(strings.ReplaceAll(s, `"`, ""))
+	if s == "" {
+		return ""
+	}
+	return `"` + s + `"`
+}
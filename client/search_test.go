@@ -0,0 +1,129 @@
+package mattermost_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestSearchQuery(t *testing.T) {
+	day := time.Date(2024, 3, 9, 23, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		query   *mattermost.SearchQuery
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", query: mattermost.NewSearchQuery()},
+		{name: "words", query: mattermost.NewSearchQuery().Words("deploy", " failed "), want: "deploy failed"},
+		{name: "word with spaces", query: mattermost.NewSearchQuery().Words("deploy failed"), want: `"deploy failed"`},
+		{name: "word like an exclusion", query: mattermost.NewSearchQuery().Words("-rf"), want: `"-rf"`},
+		{name: "word like a hashtag", query: mattermost.NewSearchQuery().Words("#ops"), want: `"#ops"`},
+		{name: "word like a filter", query: mattermost.NewSearchQuery().Words("from:alice", "a:b"), want: `"from:alice" "a:b"`},
+		{name: "phrase", query: mattermost.NewSearchQuery().Phrase(`say "hi"`), want: `"say hi"`},
+		{name: "exclude", query: mattermost.NewSearchQuery().Exclude("staging", "dev env", "-x"), want: `-staging -"dev env" -"-x"`},
+		{name: "hashtags", query: mattermost.NewSearchQuery().Hashtags("#ops", "infra"), want: "#ops #infra"},
+		{
+			name:  "users and channels",
+			query: mattermost.NewSearchQuery().From("@alice").ExcludeFrom("bob").In("~town-square").ExcludeIn("off-topic"),
+			want:  "from:alice -from:bob in:town-square -in:off-topic",
+		},
+		{name: "dates", query: mattermost.NewSearchQuery().Before(day).After(day).On(day), want: "before:2024-03-09 after:2024-03-09 on:2024-03-09"},
+		{name: "empty values", query: mattermost.NewSearchQuery().Words("", " ").Phrase(`""`).From("@").In(" "), want: ""},
+		{name: "username with a space", query: mattermost.NewSearchQuery().Words("deploy").From("alice smith"), want: "deploy", wantErr: true},
+		{name: "channel with a space", query: mattermost.NewSearchQuery().ExcludeIn("town square").In("ops"), want: "in:ops", wantErr: true},
+		{name: "hashtag with a space", query: mattermost.NewSearchQuery().Hashtags("two words"), wantErr: true},
+		{name: "username with a quote", query: mattermost.NewSearchQuery().ExcludeFrom(`al"ice`), wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.query.String(); got != tc.want {
+				t.Errorf("terms %q, want %q", got, tc.want)
+			}
+			if err := tc.query.Err(); (err != nil) != tc.wantErr {
+				t.Errorf("error %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearchPosts(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	team := s.AddTeam(&mattermost.Team{Name: "team"})
+	other := s.AddTeam(&mattermost.Team{Name: "other"})
+	town := s.AddChannel(&mattermost.Channel{TeamId: team.Id, Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	ops := s.AddChannel(&mattermost.Channel{TeamId: team.Id, Name: "ops", Type: mattermost.ChannelTypeOpen})
+	elsewhere := s.AddChannel(&mattermost.Channel{TeamId: other.Id, Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	for _, ch := range []*mattermost.Channel{town, ops, elsewhere} {
+		s.AddChannelMember(ch.Id, alice.Id)
+		s.AddChannelMember(ch.Id, bob.Id)
+	}
+	for _, p := range []struct {
+		user    *mattermost.User
+		channel *mattermost.Channel
+		message string
+	}{
+		{alice, town, "deploy failed on prod"},
+		{alice, town, "deploy ok"},
+		{alice, town, "never run rm -rf here"},
+		{alice, town, "ping from:alice"},
+		{bob, ops, "deploy failed again #ops"},
+		{bob, elsewhere, "deploy failed in the other team"},
+	} {
+		if _, _, err := s.Client(p.user.Id).CreateSimpleMessagePost(p.channel.Id, p.message, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	today := time.Now()
+	c := s.Client(alice.Id)
+
+	for _, tc := range []struct {
+		name  string
+		query *mattermost.SearchQuery
+		isOr  bool
+		want  []string
+	}{
+		{name: "words", query: mattermost.NewSearchQuery().Words("deploy", "failed"), want: []string{"deploy failed again #ops", "deploy failed on prod"}},
+		{name: "or", query: mattermost.NewSearchQuery().Words("prod", "again"), isOr: true, want: []string{"deploy failed again #ops", "deploy failed on prod"}},
+		{name: "phrase", query: mattermost.NewSearchQuery().Words("failed on prod"), want: []string{"deploy failed on prod"}},
+		{name: "from", query: mattermost.NewSearchQuery().Words("deploy").From("bob"), want: []string{"deploy failed again #ops"}},
+		{name: "exclude from", query: mattermost.NewSearchQuery().Words("failed").ExcludeFrom("bob"), want: []string{"deploy failed on prod"}},
+		{name: "in", query: mattermost.NewSearchQuery().Words("deploy").In("~ops"), want: []string{"deploy failed again #ops"}},
+		{name: "exclude in", query: mattermost.NewSearchQuery().Words("deploy").ExcludeIn("ops"), want: []string{"deploy ok", "deploy failed on prod"}},
+		{name: "exclude", query: mattermost.NewSearchQuery().Words("deploy").Exclude("failed"), want: []string{"deploy ok"}},
+		{name: "hashtag", query: mattermost.NewSearchQuery().Hashtags("ops"), want: []string{"deploy failed again #ops"}},
+		{name: "word like an exclusion", query: mattermost.NewSearchQuery().Words("-rf"), want: []string{"never run rm -rf here"}},
+		{name: "word like a filter", query: mattermost.NewSearchQuery().Words("from:alice"), want: []string{"ping from:alice"}},
+		{name: "on", query: mattermost.NewSearchQuery().Words("ok").On(today), want: []string{"deploy ok"}},
+		{name: "before", query: mattermost.NewSearchQuery().Words("ok").Before(today)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.query.Err(); err != nil {
+				t.Fatal(err)
+			}
+			_, offset := today.Zone()
+			res, _, err := c.SearchPostsWithParams(team.Id, &mattermost.SearchParameter{
+				Terms:          tc.query.String(),
+				IsOrSearch:     tc.isOr,
+				TimeZoneOffset: offset,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messages(res.ToSlice()); !slices.Equal(got, tc.want) {
+				t.Fatalf("found %q, want %q", got, tc.want)
+			}
+			for _, id := range res.Order {
+				if _, ok := res.Matches[id]; !ok {
+					t.Errorf("no matches for %s", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Login authenticates with a username or email and a password. On success the
+// session token is used for every following request, and the credentials are
+// kept in memory so that an expired session is renewed transparently.
+func (c *Client4) Login(loginId string, password string) (*User, *Response, error) {
+	return c.LoginContext(context.Background(), loginId, password)
+}
+
+func (c *Client4) LoginContext(ctx context.Context, loginId string, password string) (*User, *Response, error) {
+	m := map[string]string{
+		"login_id": loginId,
+		"password": password,
+	}
+	return c.login(ctx, m, true)
+}
+
+// LoginWithMFA authenticates a user that has multi-factor authentication
+// enabled. As one-time codes cannot be reused, the session is not renewed
+// automatically when it expires.
+func (c *Client4) LoginWithMFA(loginId, password, mfaToken string) (*User, *Response, error) {
+	return c.LoginWithMFAContext(context.Background(), loginId, password, mfaToken)
+}
+
+func (c *Client4) LoginWithMFAContext(ctx context.Context, loginId, password, mfaToken string) (*User, *Response, error) {
+	m := map[string]string{
+		"login_id": loginId,
+		"password": password,
+		"token":    mfaToken,
+	}
+	return c.login(ctx, m, false)
+}
+
+// LoginByLdap authenticates against the LDAP/AD directory configured on the server.
+func (c *Client4) LoginByLdap(loginId string, password string) (*User, *Response, error) {
+	return c.LoginByLdapContext(context.Background(), loginId, password)
+}
+
+func (c *Client4) LoginByLdapContext(ctx context.Context, loginId string, password string) (*User, *Response, error) {
+	m := map[string]string{
+		"login_id":  loginId,
+		"password":  password,
+		"ldap_only": c.boolString(true),
+	}
+	return c.login(ctx, m, true)
+}
+
+// Logout revokes the current session and clears the token and the remembered
+// credentials, even when the server call fails.
+func (c *Client4) Logout() (*Response, error) {
+	return c.LogoutContext(context.Background())
+}
+
+func (c *Client4) LogoutContext(ctx context.Context) (*Response, error) {
+	r, err := c.DoAPIPostContext(ctx, c.userLogoutRoute(), "")
+	c.authMu.Lock()
+	c.AuthToken = ""
+	c.AuthType = HeaderBearer
+	c.loginData = nil
+	c.authMu.Unlock()
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
+func (c *Client4) login(ctx context.Context, m map[string]string, remember bool) (*User, *Response, error) {
+	r, err := c.DoAPIPostContext(ctx, c.userLoginRoute(), MapToJSON(m))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+
+	token := r.Header.Get(HeaderToken)
+	if token == "" {
+		return nil, BuildResponse(r), NewAppError("Login", "api.user.login.missing_token.app_error", nil, "", http.StatusInternalServerError)
+	}
+
+	c.authMu.Lock()
+	c.AuthToken = token
+	c.AuthType = HeaderBearer
+	if remember {
+		c.loginData = m
+	} else {
+		c.loginData = nil
+	}
+	c.authMu.Unlock()
+
+	var u User
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		return nil, nil, NewAppError("Login", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &u, BuildResponse(r), nil
+}
+
+// updateSessionToken adopts a session token sent back by the server, which
+// happens when a session is created or renewed.
+func (c *Client4) updateSessionToken(rp *http.Response) {
+	token := rp.Header.Get(HeaderToken)
+	if token == "" || rp.StatusCode >= 300 {
+		return
+	}
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	c.AuthToken = token
+	c.AuthType = HeaderBearer
+}
+
+// shouldRelogin reports whether the request failed because the session
+// expired and the client knows the credentials to open a new one.
+func (c *Client4) shouldRelogin(rq *http.Request, err error) bool {
+	var appErr *AppError
+	if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	if strings.HasSuffix(rq.URL.Path, c.userLoginRoute()) || strings.HasSuffix(rq.URL.Path, c.userLogoutRoute()) {
+		return false
+	}
+	c.authMu.RLock()
+	defer c.authMu.RUnlock()
+	return c.loginData != nil
+}
+
+// relogin opens a new session with the remembered credentials. Concurrent
+// callers that failed with the same expired session share a single login.
+func (c *Client4) relogin(ctx context.Context, expiredAuth string) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	if c.authorization() != expiredAuth {
+		// Another request already renewed the session.
+		return nil
+	}
+
+	c.authMu.RLock()
+	m := c.loginData
+	c.authMu.RUnlock()
+	if m == nil {
+		return errors.New("mattermost: no credentials to renew the session")
+	}
+
+	_, _, err := c.login(ctx, m, true)
+	return err
+}
+
+// acquireAuthorization returns the Authorization header for a request and
+// counts the request as in flight with it until release is called, so that
+// the token is not revoked under it.
+func (c *Client4) acquireAuthorization() (auth string, release func()) {
+	c.authMu.RLock()
+	defer c.authMu.RUnlock()
+	auth = c.authorizationLocked()
+	if auth == "" {
+		return "", func() {}
+	}
+
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if c.inflight == nil {
+		c.inflight = map[string]int{}
+	}
+	c.inflight[auth]++
+
+	return auth, func() {
+		c.inflightMu.Lock()
+		defer c.inflightMu.Unlock()
+		if c.inflight[auth]--; c.inflight[auth] > 0 {
+			return
+		}
+		delete(c.inflight, auth)
+		if c.drained != nil {
+			close(c.drained)
+			c.drained = nil
+		}
+	}
+}
+
+// waitForRequests blocks until no request sent with auth is in flight or ctx
+// is done.
+func (c *Client4) waitForRequests(ctx context.Context, auth string) error {
+	for {
+		c.inflightMu.Lock()
+		if c.inflight[auth] == 0 {
+			c.inflightMu.Unlock()
+			return nil
+		}
+		if c.drained == nil {
+			c.drained = make(chan struct{})
+		}
+		drained := c.drained
+		c.inflightMu.Unlock()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
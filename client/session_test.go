@@ -0,0 +1,165 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestLogin(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	s.AddUser(&mattermost.User{Username: "alice", Email: "alice@example.com"}, "secret")
+	s.AddUser(&mattermost.User{Username: "bob", MfaActive: true}, "secret")
+
+	for _, tc := range []struct {
+		name    string
+		login   func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error)
+		want    string
+		wantErr error
+	}{
+		{name: "username", login: func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error) {
+			return c.Login("alice", "secret")
+		}, want: "alice"},
+		{name: "email", login: func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error) {
+			return c.Login("alice@example.com", "secret")
+		}, want: "alice"},
+		{name: "ldap", login: func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error) {
+			return c.LoginByLdap("alice", "secret")
+		}, want: "alice"},
+		{name: "wrong password", login: func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error) {
+			return c.Login("alice", "wrong")
+		}, wantErr: mattermost.ErrUnauthorized},
+		{name: "missing MFA token", login: func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error) {
+			return c.Login("bob", "secret")
+		}, wantErr: mattermost.ErrUnauthorized},
+		{name: "MFA", login: func(c *mattermost.Client4) (*mattermost.User, *mattermost.Response, error) {
+			return c.LoginWithMFA("bob", "secret", "123456")
+		}, want: "bob"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := s.Client("")
+			u, _, err := tc.login(c)
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				if c.AuthToken != "" {
+					t.Fatal("token set after a failed login")
+				}
+				return
+			}
+			if u.Username != tc.want || c.AuthToken == "" {
+				t.Fatalf("user %q, token %q", u.Username, c.AuthToken)
+			}
+			me, _, err := c.GetMe("")
+			if err != nil || me.Username != tc.want {
+				t.Fatalf("GetMe = %v, %v", me, err)
+			}
+		})
+	}
+}
+
+// loginCounter counts the login requests sent by a client.
+func loginCounter(n *atomic.Int32) mattermost.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(rq.URL.Path, "/users/login") {
+				n.Add(1)
+			}
+			return next.RoundTrip(rq)
+		})
+	}
+}
+
+func TestSessionRenewal(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	s.AddUser(&mattermost.User{Username: "bob", MfaActive: true}, "secret")
+
+	t.Run("expired session is renewed once for concurrent requests", func(t *testing.T) {
+		c := s.Client("")
+		var logins atomic.Int32
+		c.Use(loginCounter(&logins))
+		if _, _, err := c.Login("alice", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		expired := c.AuthToken
+		s.RevokeSession(expired)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := c.GetMeContext(context.Background(), "")
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if logins.Load() != 2 {
+			t.Fatalf("%d logins, want 2", logins.Load())
+		}
+		if c.AuthToken == expired {
+			t.Fatal("token not renewed")
+		}
+	})
+
+	t.Run("MFA sessions are not renewed", func(t *testing.T) {
+		c := s.Client("")
+		if _, _, err := c.LoginWithMFA("bob", "secret", "123456"); err != nil {
+			t.Fatal(err)
+		}
+		s.RevokeSession(c.AuthToken)
+		if _, _, err := c.GetMe(""); !errors.Is(err, mattermost.ErrUnauthorized) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+
+	t.Run("logout forgets the credentials", func(t *testing.T) {
+		c := s.Client("")
+		if _, _, err := c.Login("alice", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Logout(); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.GetMe(""); !errors.Is(err, mattermost.ErrUnauthorized) || c.AuthToken != "" {
+			t.Fatalf("err = %v, token %q", err, c.AuthToken)
+		}
+	})
+
+	t.Run("401 without a response from a middleware", func(t *testing.T) {
+		c := s.Client("")
+		if _, _, err := c.Login("alice", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		var faults atomic.Int32
+		c.Use(func(next http.RoundTripper) http.RoundTripper {
+			return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+				if strings.HasSuffix(rq.URL.Path, "/users/me") && faults.Add(1) == 1 {
+					return nil, &mattermost.AppError{Id: "injected", StatusCode: http.StatusUnauthorized}
+				}
+				return next.RoundTrip(rq)
+			})
+		})
+		if _, _, err := c.GetMe(""); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
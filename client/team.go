@@ -1,6 +1,7 @@
 package mattermost
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 )
@@ -28,7 +29,11 @@ type Team struct {
 
 // GetTeamByName returns a team based on the provided team name string.
 func (c *Client4) GetTeamByName(name, etag string) (*Team, *Response, error) {
-	r, err := c.DoAPIGet(c.teamByNameRoute(name), etag)
+	return c.GetTeamByNameContext(context.Background(), name, etag)
+}
+
+func (c *Client4) GetTeamByNameContext(ctx context.Context, name, etag string) (*Team, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.teamByNameRoute(name), etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -0,0 +1,16 @@
+package mattermost
+
+// ThreadResponse is a thread followed by a user, as returned by the threads API
+// and the thread_updated websocket event.
+type ThreadResponse struct {
+	PostId         string  `json:"id"`
+	ReplyCount     int64   `json:"reply_count"`
+	LastReplyAt    int64   `json:"last_reply_at"`
+	LastViewedAt   int64   `json:"last_viewed_at"`
+	Participants   []*User `json:"participants"`
+	Post           *Post   `json:"post"`
+	UnreadReplies  int64   `json:"unread_replies"`
+	UnreadMentions int64   `json:"unread_mentions"`
+	IsUrgent       bool    `json:"is_urgent"`
+	DeleteAt       int64   `json:"delete_at"`
+}
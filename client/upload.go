@@ -0,0 +1,179 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// UploadChunkSize is the amount of data UploadLargeFile sends per request.
+const UploadChunkSize = 8 << 20
+
+type UploadType string
+
+const (
+	UploadTypeAttachment UploadType = "attachment"
+	UploadTypeImport     UploadType = "import"
+)
+
+// UploadSession tracks a resumable upload. FileOffset is the number of bytes
+// the server has received so far.
+type UploadSession struct {
+	Id         string     `json:"id"`
+	Type       UploadType `json:"type"`
+	CreateAt   int64      `json:"create_at"`
+	UserId     string     `json:"user_id"`
+	ChannelId  string     `json:"channel_id,omitempty"`
+	Filename   string     `json:"filename"`
+	FileSize   int64      `json:"file_size"`
+	FileOffset int64      `json:"file_offset"`
+	RemoteId   string     `json:"remote_id,omitempty"`
+	ReqFileId  string     `json:"req_file_id,omitempty"`
+}
+
+// CreateUpload starts an upload session. Type, Filename and FileSize are
+// required, and ChannelId for attachments.
+func (c *Client4) CreateUpload(us *UploadSession) (*UploadSession, *Response, error) {
+	return c.CreateUploadContext(context.Background(), us)
+}
+
+func (c *Client4) CreateUploadContext(ctx context.Context, us *UploadSession) (*UploadSession, *Response, error) {
+	buf, err := json.Marshal(us)
+	if err != nil {
+		return nil, nil, NewAppError("CreateUpload", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	r, err := c.DoAPIPostContext(ctx, c.uploadsRoute(), string(buf))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var res UploadSession
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return nil, nil, NewAppError("CreateUpload", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &res, BuildResponse(r), nil
+}
+
+// GetUpload gets an upload session, whose FileOffset tells where to resume.
+// Sessions are deleted once the upload completes.
+func (c *Client4) GetUpload(uploadId string) (*UploadSession, *Response, error) {
+	return c.GetUploadContext(context.Background(), uploadId)
+}
+
+func (c *Client4) GetUploadContext(ctx context.Context, uploadId string) (*UploadSession, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.uploadRoute(uploadId), "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var res UploadSession
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return nil, nil, NewAppError("GetUpload", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &res, BuildResponse(r), nil
+}
+
+// UploadData appends data to an upload session, starting at its current
+// FileOffset. It returns the FileInfo of the file once the upload is complete,
+// and nil while more data is expected.
+func (c *Client4) UploadData(uploadId string, data io.Reader) (*FileInfo, *Response, error) {
+	return c.UploadDataContext(context.Background(), uploadId, data)
+}
+
+func (c *Client4) UploadDataContext(ctx context.Context, uploadId string, data io.Reader) (*FileInfo, *Response, error) {
+	r, err := c.DoAPIRequestReaderContext(ctx, http.MethodPost, c.APIURL+c.uploadRoute(uploadId), data, map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	if r.StatusCode == http.StatusNoContent {
+		return nil, BuildResponse(r), nil
+	}
+	var info FileInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		return nil, nil, NewAppError("UploadData", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &info, BuildResponse(r), nil
+}
+
+// UploadLargeFile uploads size bytes of data to a channel through an upload
+// session, in chunks of UploadChunkSize. Interrupted chunks are resumed from
+// the offset the server reports. The session id is returned with the error
+// when the upload fails, so that it can be completed later with ResumeUpload.
+func (c *Client4) UploadLargeFile(channelId, filename string, data io.ReaderAt, size int64) (*FileInfo, string, error) {
+	return c.UploadLargeFileContext(context.Background(), channelId, filename, data, size)
+}
+
+func (c *Client4) UploadLargeFileContext(ctx context.Context, channelId, filename string, data io.ReaderAt, size int64) (*FileInfo, string, error) {
+	us, _, err := c.CreateUploadContext(ctx, &UploadSession{
+		Type:      UploadTypeAttachment,
+		ChannelId: channelId,
+		Filename:  filename,
+		FileSize:  size,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := c.ResumeUploadContext(ctx, us.Id, data)
+	return info, us.Id, err
+}
+
+// ResumeUpload sends the data an upload session is missing, starting at the
+// offset the server has, and returns the FileInfo of the completed file. data
+// must hold the whole file. Network errors and transient server errors are
+// retried following the client's RetryPolicy, or the default policy, with
+// the attempts counted across the requests without progress.
+func (c *Client4) ResumeUpload(uploadId string, data io.ReaderAt) (*FileInfo, error) {
+	return c.ResumeUploadContext(context.Background(), uploadId, data)
+}
+
+func (c *Client4) ResumeUploadContext(ctx context.Context, uploadId string, data io.ReaderAt) (*FileInfo, error) {
+	policy := c.RetryPolicy
+	if policy == nil {
+		policy = NewRetryPolicy()
+	}
+	// The loop below does the retrying, so the requests are sent once.
+	rctx := withoutRetries(ctx)
+
+	failures := 0
+	for {
+		us, r, err := c.GetUploadContext(rctx, uploadId)
+		if err == nil {
+			if us.FileOffset >= us.FileSize {
+				return nil, NewAppError("ResumeUpload", "api.upload.upload_data.already_complete.app_error", nil, "uploadId="+uploadId, http.StatusBadRequest)
+			}
+
+			chunk := io.NewSectionReader(data, us.FileOffset, min(UploadChunkSize, us.FileSize-us.FileOffset))
+			var info *FileInfo
+			info, r, err = c.UploadDataContext(rctx, uploadId, chunk)
+			if err == nil {
+				if info != nil {
+					return info, nil
+				}
+				failures = 0
+				continue
+			}
+		}
+
+		if !IsNetworkError(err) && !errors.Is(err, ErrServerUnavailable) && !errors.Is(err, ErrRateLimited) {
+			return nil, err
+		}
+		failures++
+		if failures >= policy.MaxAttempts {
+			return nil, err
+		}
+		var rp *http.Response
+		if r != nil {
+			rp = &http.Response{StatusCode: r.StatusCode, Header: r.Header}
+		}
+		delay, ok := policy.backoff(failures, rp)
+		if !ok {
+			return nil, err
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package mattermost_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+// fastRetries retries after a millisecond.
+var fastRetries = &mattermost.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+// uploadChunks passes the requests sending upload data to edit, numbered from
+// 1, and records how many bytes of each reached the server.
+func uploadChunks(mu *sync.Mutex, sent *[]int64, edit func(n int, rq *http.Request, next http.RoundTripper) (*http.Response, error)) mattermost.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+			if rq.Method != http.MethodPost || !strings.Contains(rq.URL.Path, "/uploads/") {
+				return next.RoundTrip(rq)
+			}
+			mu.Lock()
+			*sent = append(*sent, 0)
+			n := len(*sent)
+			mu.Unlock()
+			return edit(n, rq, mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+				mu.Lock()
+				(*sent)[n-1] = rq.ContentLength
+				mu.Unlock()
+				return next.RoundTrip(rq)
+			}))
+		})
+	}
+}
+
+// truncated returns rq with only the first n bytes of its body.
+func truncated(rq *http.Request, n int64) *http.Request {
+	rq = rq.Clone(rq.Context())
+	rq.Body = io.NopCloser(io.LimitReader(rq.Body, n))
+	rq.ContentLength = n
+	rq.GetBody = nil
+	return rq
+}
+
+func unavailable(rq *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     "503 Service Unavailable",
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"id":"api.unavailable","status_code":503}`)),
+		Request:    rq,
+	}
+}
+
+func TestUploadLargeFile(t *testing.T) {
+	rejected := errors.New("rejected")
+	pass := func(_ int, rq *http.Request, next http.RoundTripper) (*http.Response, error) {
+		return next.RoundTrip(rq)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		size     int
+		edit     func(n int, rq *http.Request, next http.RoundTripper) (*http.Response, error)
+		wantSent []int64
+		wantErr  error
+	}{
+		{name: "one chunk", size: 1000, edit: pass, wantSent: []int64{1000}},
+		{name: "chunks", size: mattermost.UploadChunkSize + 1000, edit: pass, wantSent: []int64{mattermost.UploadChunkSize, 1000}},
+		{
+			// The connection breaks after part of the chunk reached the
+			// server, which keeps it: the next chunk starts where it stopped.
+			name: "resumed from the server offset",
+			size: 3000,
+			edit: func(n int, rq *http.Request, next http.RoundTripper) (*http.Response, error) {
+				if n == 1 {
+					if _, err := next.RoundTrip(truncated(rq, 1200)); err != nil {
+						return nil, err
+					}
+					return nil, io.ErrUnexpectedEOF
+				}
+				return next.RoundTrip(rq)
+			},
+			wantSent: []int64{1200, 1800},
+		},
+		{
+			name: "transient server error",
+			size: 1000,
+			edit: func(n int, rq *http.Request, next http.RoundTripper) (*http.Response, error) {
+				if n == 1 {
+					return unavailable(rq), nil
+				}
+				return next.RoundTrip(rq)
+			},
+			wantSent: []int64{0, 1000},
+		},
+		{
+			name: "gives up",
+			size: 1000,
+			edit: func(_ int, rq *http.Request, _ http.RoundTripper) (*http.Response, error) {
+				return unavailable(rq), nil
+			},
+			wantSent: []int64{0, 0, 0},
+			wantErr:  mattermost.ErrServerUnavailable,
+		},
+		{
+			name: "not retried",
+			size: 1000,
+			edit: func(_ int, rq *http.Request, _ http.RoundTripper) (*http.Response, error) {
+				return nil, rejected
+			},
+			wantSent: []int64{0},
+			wantErr:  rejected,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, c, ch, _ := fileServer(t)
+			defer s.Close()
+			c.RetryPolicy = fastRetries
+			var mu sync.Mutex
+			var sent []int64
+			c.Use(uploadChunks(&mu, &sent, tc.edit))
+			data := testData(tc.size)
+
+			info, uploadId, err := c.UploadLargeFile(ch.Id, "data.bin", bytes.NewReader(data), int64(len(data)))
+			if !slices.Equal(sent, tc.wantSent) {
+				t.Errorf("sent %v, want %v", sent, tc.wantSent)
+			}
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				if uploadId == "" {
+					t.Fatal("no upload id to resume")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, content := s.File(info.Id)
+			if stored == nil || stored.Name != "data.bin" || stored.ChannelId != ch.Id || !bytes.Equal(content, data) {
+				t.Fatalf("stored %+v, %d bytes", stored, len(content))
+			}
+		})
+	}
+}
+
+func TestResumeUpload(t *testing.T) {
+	s, c, ch, private := fileServer(t)
+	defer s.Close()
+	c.RetryPolicy = fastRetries
+	data := testData(5000)
+
+	var broken atomic.Bool
+	broken.Store(true)
+	var mu sync.Mutex
+	var sent []int64
+	c.Use(uploadChunks(&mu, &sent, func(_ int, rq *http.Request, next http.RoundTripper) (*http.Response, error) {
+		if broken.Load() {
+			// Part of the data arrives before every failure.
+			if _, err := next.RoundTrip(truncated(rq, 1000)); err != nil {
+				return nil, err
+			}
+			return unavailable(rq), nil
+		}
+		return next.RoundTrip(rq)
+	}))
+
+	_, uploadId, err := c.UploadLargeFile(ch.Id, "data.bin", bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, mattermost.ErrServerUnavailable) {
+		t.Fatalf("err = %v", err)
+	}
+	us, _, err := c.GetUpload(uploadId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if us.FileOffset != 3000 || us.FileSize != 5000 {
+		t.Fatalf("session %+v", us)
+	}
+
+	broken.Store(false)
+	info, err := c.ResumeUpload(uploadId, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, content := s.File(info.Id); !bytes.Equal(content, data) {
+		t.Fatalf("stored %d bytes", len(content))
+	}
+	if want := []int64{1000, 1000, 1000, 2000}; !slices.Equal(sent, want) {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+
+	t.Run("completed session", func(t *testing.T) {
+		if _, err := c.ResumeUpload(uploadId, bytes.NewReader(data)); !errors.Is(err, mattermost.ErrNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+
+	t.Run("invalid session", func(t *testing.T) {
+		for _, us := range []*mattermost.UploadSession{
+			{Type: mattermost.UploadTypeAttachment, ChannelId: ch.Id, Filename: "data.bin"},
+			{Type: mattermost.UploadTypeAttachment, ChannelId: ch.Id, FileSize: 10},
+		} {
+			var appErr *mattermost.AppError
+			if _, _, err := c.CreateUpload(us); !errors.As(err, &appErr) || appErr.StatusCode != http.StatusBadRequest {
+				t.Fatalf("%+v: err = %v", us, err)
+			}
+		}
+		us := &mattermost.UploadSession{Type: mattermost.UploadTypeAttachment, ChannelId: private.Id, Filename: "data.bin", FileSize: 10}
+		if _, _, err := c.CreateUpload(us); !errors.Is(err, mattermost.ErrForbidden) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestResumeUploadCountsAttemptsOnce(t *testing.T) {
+	s, c, ch, _ := fileServer(t)
+	defer s.Close()
+	c.RetryPolicy = fastRetries
+	us, _, err := c.CreateUpload(&mattermost.UploadSession{Type: mattermost.UploadTypeAttachment, ChannelId: ch.Id, Filename: "data.bin", FileSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server is unavailable once the first request got the offset.
+	var requests []string
+	c.Use(func(next http.RoundTripper) http.RoundTripper {
+		return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+			if !strings.Contains(rq.URL.Path, "/uploads/") {
+				return next.RoundTrip(rq)
+			}
+			requests = append(requests, rq.Method)
+			if len(requests) == 1 {
+				return next.RoundTrip(rq)
+			}
+			return unavailable(rq), nil
+		})
+	})
+
+	if _, err := c.ResumeUpload(us.Id, bytes.NewReader(testData(10))); !errors.Is(err, mattermost.ErrServerUnavailable) {
+		t.Fatalf("err = %v", err)
+	}
+	// The client does not retry the requests again on its own.
+	if want := []string{http.MethodGet, http.MethodPost, http.MethodGet, http.MethodGet}; !slices.Equal(requests, want) {
+		t.Fatalf("requests %v, want %v", requests, want)
+	}
+}
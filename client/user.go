@@ -1,6 +1,7 @@
 package mattermost
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 )
@@ -94,7 +95,11 @@ type User struct {
 type UserMap map[string]*User
 
 func (c *Client4) GetMe(etag string) (*User, *Response, error) {
-	r, err := c.DoAPIGet(c.userRoute(Me), etag)
+	return c.GetMeContext(context.Background(), etag)
+}
+
+func (c *Client4) GetMeContext(ctx context.Context, etag string) (*User, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.userRoute(Me), etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -111,7 +116,11 @@ func (c *Client4) GetMe(etag string) (*User, *Response, error) {
 
 // GetUser returns a user based on the provided user id string.
 func (c *Client4) GetUser(userId, etag string) (*User, *Response, error) {
-	r, err := c.DoAPIGet(c.userRoute(userId), etag)
+	return c.GetUserContext(context.Background(), userId, etag)
+}
+
+func (c *Client4) GetUserContext(ctx context.Context, userId, etag string) (*User, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.userRoute(userId), etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -128,7 +137,11 @@ func (c *Client4) GetUser(userId, etag string) (*User, *Response, error) {
 
 // GetUserByUsername returns a user based on the provided user name string.
 func (c *Client4) GetUserByUsername(userName, etag string) (*User, *Response, error) {
-	r, err := c.DoAPIGet(c.userByUsernameRoute(userName), etag)
+	return c.GetUserByUsernameContext(context.Background(), userName, etag)
+}
+
+func (c *Client4) GetUserByUsernameContext(ctx context.Context, userName, etag string) (*User, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.userByUsernameRoute(userName), etag)
 	if err != nil {
 		return nil, BuildResponse(r), err
 	}
@@ -144,12 +157,16 @@ func (c *Client4) GetUserByUsername(userName, etag string) (*User, *Response, er
 }
 
 func (c *Client4) UpdateThreadFollowForUser(userId, teamId, threadId string, state bool) (*Response, error) {
+	return c.UpdateThreadFollowForUserContext(context.Background(), userId, teamId, threadId, state)
+}
+
+func (c *Client4) UpdateThreadFollowForUserContext(ctx context.Context, userId, teamId, threadId string, state bool) (*Response, error) {
 	var err error
 	var r *http.Response
 	if state {
-		r, err = c.DoAPIPut(c.userThreadRoute(userId, teamId, threadId)+"/following", "")
+		r, err = c.DoAPIPutContext(ctx, c.userThreadRoute(userId, teamId, threadId)+"/following", "")
 	} else {
-		r, err = c.DoAPIDelete(c.userThreadRoute(userId, teamId, threadId) + "/following")
+		r, err = c.DoAPIDeleteContext(ctx, c.userThreadRoute(userId, teamId, threadId)+"/following")
 	}
 	if err != nil {
 		return BuildResponse(r), err
@@ -160,16 +177,22 @@ func (c *Client4) UpdateThreadFollowForUser(userId, teamId, threadId string, sta
 }
 
 func (c *Client4) UpdateThreadFollowAllUsersInChannel(channelID, postID string, state bool) error {
-	channel, _, err := c.GetChannel(channelID, "")
-	if err != nil {
-		return err
-	}
-	users, _, err := c.GetChannelMembers(channelID, 0, 200, "")
+	return c.UpdateThreadFollowAllUsersInChannelContext(context.Background(), channelID, postID, state)
+}
+
+func (c *Client4) UpdateThreadFollowAllUsersInChannelContext(ctx context.Context, channelID, postID string, state bool) error {
+	channel, _, err := c.GetChannelContext(ctx, channelID, "")
 	if err != nil {
 		return err
 	}
-	for _, user := range users {
-		_, _ = c.UpdateThreadFollowForUser(user.UserId, channel.TeamId, postID, state)
+	for member, err := range c.AllChannelMembers(ctx, channelID) {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, _ = c.UpdateThreadFollowForUserContext(ctx, member.UserId, channel.TeamId, postID, state)
 	}
 	return nil
 }
@@ -0,0 +1,184 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type UserAccessToken struct {
+	Id          string `json:"id"`
+	Token       string `json:"token,omitempty"`
+	UserId      string `json:"user_id"`
+	Description string `json:"description"`
+	IsActive    bool   `json:"is_active"`
+}
+
+type UserAccessTokenSearch struct {
+	Term string `json:"term"`
+}
+
+// CreateUserAccessToken creates a personal access token for the user. The
+// token value is only returned by this call.
+func (c *Client4) CreateUserAccessToken(userId, description string) (*UserAccessToken, *Response, error) {
+	return c.CreateUserAccessTokenContext(context.Background(), userId, description)
+}
+
+func (c *Client4) CreateUserAccessTokenContext(ctx context.Context, userId, description string) (*UserAccessToken, *Response, error) {
+	requestBody := map[string]string{"description": description}
+	r, err := c.DoAPIPostContext(ctx, c.userRoute(userId)+"/tokens", MapToJSON(requestBody))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var t UserAccessToken
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		return nil, nil, NewAppError("CreateUserAccessToken", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &t, BuildResponse(r), nil
+}
+
+// GetUserAccessTokens returns a page of the tokens of every user. Requires the
+// manage_system permission.
+func (c *Client4) GetUserAccessTokens(page int, perPage int) ([]*UserAccessToken, *Response, error) {
+	return c.GetUserAccessTokensContext(context.Background(), page, perPage)
+}
+
+func (c *Client4) GetUserAccessTokensContext(ctx context.Context, page int, perPage int) ([]*UserAccessToken, *Response, error) {
+	query := fmt.Sprintf("?page=%v&per_page=%v", page, perPage)
+	return c.getUserAccessTokens(ctx, "GetUserAccessTokens", c.userAccessTokensRoute()+query)
+}
+
+// GetUserAccessTokensForUser returns a page of the tokens of a user. Token
+// values are not included.
+func (c *Client4) GetUserAccessTokensForUser(userId string, page, perPage int) ([]*UserAccessToken, *Response, error) {
+	return c.GetUserAccessTokensForUserContext(context.Background(), userId, page, perPage)
+}
+
+func (c *Client4) GetUserAccessTokensForUserContext(ctx context.Context, userId string, page, perPage int) ([]*UserAccessToken, *Response, error) {
+	query := fmt.Sprintf("?page=%v&per_page=%v", page, perPage)
+	return c.getUserAccessTokens(ctx, "GetUserAccessTokensForUser", c.userRoute(userId)+"/tokens"+query)
+}
+
+// GetUserAccessToken returns a token by id. The token value is not included.
+func (c *Client4) GetUserAccessToken(tokenId string) (*UserAccessToken, *Response, error) {
+	return c.GetUserAccessTokenContext(context.Background(), tokenId)
+}
+
+func (c *Client4) GetUserAccessTokenContext(ctx context.Context, tokenId string) (*UserAccessToken, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, c.userAccessTokenRoute(tokenId), "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var t UserAccessToken
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		return nil, nil, NewAppError("GetUserAccessToken", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return &t, BuildResponse(r), nil
+}
+
+// SearchUserAccessTokens returns the tokens whose id, owner or description
+// matches the search term.
+func (c *Client4) SearchUserAccessTokens(search *UserAccessTokenSearch) ([]*UserAccessToken, *Response, error) {
+	return c.SearchUserAccessTokensContext(context.Background(), search)
+}
+
+func (c *Client4) SearchUserAccessTokensContext(ctx context.Context, search *UserAccessTokenSearch) ([]*UserAccessToken, *Response, error) {
+	searchJSON, err := json.Marshal(search)
+	if err != nil {
+		return nil, nil, NewAppError("SearchUserAccessTokens", "api.marshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	r, err := c.DoAPIPostContext(ctx, c.userAccessTokensRoute()+"/search", string(searchJSON))
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var list []*UserAccessToken
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, nil, NewAppError("SearchUserAccessTokens", "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return list, BuildResponse(r), nil
+}
+
+// RevokeUserAccessToken deletes a token and the sessions created with it.
+func (c *Client4) RevokeUserAccessToken(tokenId string) (*Response, error) {
+	return c.RevokeUserAccessTokenContext(context.Background(), tokenId)
+}
+
+func (c *Client4) RevokeUserAccessTokenContext(ctx context.Context, tokenId string) (*Response, error) {
+	return c.userAccessTokenAction(ctx, "/revoke", tokenId)
+}
+
+// DisableUserAccessToken deactivates a token without deleting it.
+func (c *Client4) DisableUserAccessToken(tokenId string) (*Response, error) {
+	return c.DisableUserAccessTokenContext(context.Background(), tokenId)
+}
+
+func (c *Client4) DisableUserAccessTokenContext(ctx context.Context, tokenId string) (*Response, error) {
+	return c.userAccessTokenAction(ctx, "/disable", tokenId)
+}
+
+// EnableUserAccessToken reactivates a disabled token.
+func (c *Client4) EnableUserAccessToken(tokenId string) (*Response, error) {
+	return c.EnableUserAccessTokenContext(context.Background(), tokenId)
+}
+
+func (c *Client4) EnableUserAccessTokenContext(ctx context.Context, tokenId string) (*Response, error) {
+	return c.userAccessTokenAction(ctx, "/enable", tokenId)
+}
+
+// RotateUserAccessToken creates a new token for the user, switches the client
+// to it and revokes the token identified by oldTokenId once the requests sent
+// with the previous token have completed. When revoking the old token fails the
+// client still uses the new token, which is returned with the error.
+func (c *Client4) RotateUserAccessToken(userId, oldTokenId, description string) (*UserAccessToken, error) {
+	return c.RotateUserAccessTokenContext(context.Background(), userId, oldTokenId, description)
+}
+
+// RotateUserAccessTokenContext is RotateUserAccessToken bound to ctx. When ctx
+// is done before the requests in flight complete, the old token is left active
+// and ctx's error is returned with the new token.
+func (c *Client4) RotateUserAccessTokenContext(ctx context.Context, userId, oldTokenId, description string) (*UserAccessToken, error) {
+	token, _, err := c.CreateUserAccessTokenContext(ctx, userId, description)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := c.authorization()
+	c.SetToken(token.Token)
+
+	if oldTokenId != "" {
+		if err := c.waitForRequests(ctx, previous); err != nil {
+			return token, err
+		}
+		if _, err := c.RevokeUserAccessTokenContext(ctx, oldTokenId); err != nil {
+			return token, err
+		}
+	}
+	return token, nil
+}
+
+func (c *Client4) getUserAccessTokens(ctx context.Context, where, url string) ([]*UserAccessToken, *Response, error) {
+	r, err := c.DoAPIGetContext(ctx, url, "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	var list []*UserAccessToken
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, nil, NewAppError(where, "api.unmarshal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return list, BuildResponse(r), nil
+}
+
+func (c *Client4) userAccessTokenAction(ctx context.Context, action, tokenId string) (*Response, error) {
+	requestBody := map[string]string{"token_id": tokenId}
+	r, err := c.DoAPIPostContext(ctx, c.userAccessTokensRoute()+action, MapToJSON(requestBody))
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
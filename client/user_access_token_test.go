@@ -0,0 +1,137 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+// holdMe returns a middleware that holds GET /users/me requests after
+// signalling started until release is closed.
+func holdMe(started chan<- struct{}, release <-chan struct{}) mattermost.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return mattermost.RoundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(rq.URL.Path, "/users/me") {
+				started <- struct{}{}
+				<-release
+			}
+			return next.RoundTrip(rq)
+		})
+	}
+}
+
+func TestRotateUserAccessToken(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	u := s.AddUser(&mattermost.User{Username: "bot"}, "secret")
+
+	// newTokenClient returns a client authenticated with a fresh personal
+	// access token of u.
+	newTokenClient := func(t *testing.T) (*mattermost.Client4, *mattermost.UserAccessToken) {
+		t.Helper()
+		old, _, err := s.Client(u.Id).CreateUserAccessToken(u.Id, "old")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := s.Client("")
+		c.SetToken(old.Token)
+		return c, old
+	}
+	isActive := func(token string) bool {
+		c := s.Client("")
+		c.SetToken(token)
+		_, _, err := c.GetMe("")
+		return err == nil
+	}
+
+	t.Run("idle", func(t *testing.T) {
+		c, old := newTokenClient(t)
+		token, err := c.RotateUserAccessToken(u.Id, old.Id, "new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.AuthToken != token.Token || token.Description != "new" {
+			t.Fatalf("client token %q, rotated %+v", c.AuthToken, token)
+		}
+		if isActive(old.Token) || !isActive(token.Token) {
+			t.Fatal("old token not replaced")
+		}
+	})
+
+	t.Run("waits for requests in flight", func(t *testing.T) {
+		c, old := newTokenClient(t)
+		started, release := make(chan struct{}), make(chan struct{})
+		c.Use(holdMe(started, release))
+
+		me := make(chan error, 1)
+		go func() {
+			_, _, err := c.GetMe("")
+			me <- err
+		}()
+		<-started
+
+		rotated := make(chan error, 1)
+		go func() {
+			_, err := c.RotateUserAccessToken(u.Id, old.Id, "new")
+			rotated <- err
+		}()
+		select {
+		case err := <-rotated:
+			t.Fatalf("rotated with a request in flight: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if !isActive(old.Token) {
+			t.Fatal("old token revoked with a request in flight")
+		}
+
+		close(release)
+		if err := <-me; err != nil {
+			t.Fatalf("request in flight failed: %v", err)
+		}
+		if err := <-rotated; err != nil {
+			t.Fatal(err)
+		}
+		if isActive(old.Token) {
+			t.Fatal("old token not revoked")
+		}
+	})
+
+	t.Run("context done while waiting", func(t *testing.T) {
+		c, old := newTokenClient(t)
+		started, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		c.Use(holdMe(started, release))
+
+		go func() { _, _, _ = c.GetMe("") }()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		token, err := c.RotateUserAccessTokenContext(ctx, u.Id, old.Id, "new")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v", err)
+		}
+		if token == nil || c.AuthToken != token.Token {
+			t.Fatal("client not switched to the new token")
+		}
+		if !isActive(old.Token) {
+			t.Fatal("old token revoked")
+		}
+	})
+
+	t.Run("create fails", func(t *testing.T) {
+		c, old := newTokenClient(t)
+		if _, err := c.RotateUserAccessToken(mattermosttest.NewId(), old.Id, "new"); !errors.Is(err, mattermost.ErrNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+		if c.AuthToken != old.Token || !isActive(old.Token) {
+			t.Fatal("old token replaced")
+		}
+	})
+}
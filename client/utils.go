@@ -1,6 +1,7 @@
 package mattermost
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -31,20 +32,19 @@ func GetAttachmentColor(level string) string {
 	return colorDefault
 }
 
+// AppErrorFromJSON decodes an AppError. As the status of the response is not
+// known, a body that is not a JSON AppError gives a 500 error; prefer
+// AppErrorFromResponse, which keeps the real status and request id.
 func AppErrorFromJSON(data io.Reader) *AppError {
-	str := ""
-	bytes, rerr := io.ReadAll(data)
+	body, rerr := io.ReadAll(data)
 	if rerr != nil {
-		str = rerr.Error()
-	} else {
-		str = string(bytes)
+		body = []byte(rerr.Error())
 	}
 
-	decoder := json.NewDecoder(strings.NewReader(str))
 	var er AppError
-	err := decoder.Decode(&er)
+	err := json.NewDecoder(bytes.NewReader(body)).Decode(&er)
 	if err != nil {
-		return NewAppError("AppErrorFromJSON", "model.utils.decode_json.app_error", nil, "body: "+str, http.StatusInternalServerError).Wrap(err)
+		return NewAppError("AppErrorFromJSON", appErrorDecodeJSONId, nil, errorBodyDetails(body), http.StatusInternalServerError).Wrap(err)
 	}
 	return &er
 }
@@ -107,6 +107,11 @@ func (er *AppError) Wrap(err error) *AppError {
 
 type StringMap map[string]string
 
+func MapToJSON(objmap map[string]string) string {
+	b, _ := json.Marshal(objmap)
+	return string(b)
+}
+
 func ArrayToJSON(objmap []string) string {
 	b, _ := json.Marshal(objmap)
 	return string(b)
@@ -0,0 +1,392 @@
+package mattermost
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	WebsocketAuthenticationChallenge = "authentication_challenge"
+
+	WebsocketEventHello = "hello"
+
+	// WebsocketEventMissedEvents is generated by WebSocketClient, not by the
+	// server, when events may have been lost: the sequence numbers skipped or
+	// the connection could not be resumed after a reconnect. Consumers should
+	// resynchronize their state through the REST API.
+	WebsocketEventMissedEvents = "client_missed_events"
+
+	DefaultWebSocketPingInterval      = 30 * time.Second
+	DefaultWebSocketPongTimeout       = 10 * time.Second
+	DefaultWebSocketMinReconnectDelay = time.Second
+	DefaultWebSocketMaxReconnectDelay = time.Minute
+
+	websocketWriteTimeout     = 10 * time.Second
+	websocketHandshakeTimeout = 45 * time.Second
+	websocketChannelSize      = 100
+)
+
+type WebsocketBroadcast struct {
+	OmitUsers             map[string]bool `json:"omit_users"`
+	UserId                string          `json:"user_id"`
+	ChannelId             string          `json:"channel_id"`
+	TeamId                string          `json:"team_id"`
+	ConnectionId          string          `json:"connection_id"`
+	OmitConnectionId      string          `json:"omit_connection_id"`
+	ContainsSanitizedData bool            `json:"contains_sanitized_data,omitempty"`
+	ContainsSensitiveData bool            `json:"contains_sensitive_data,omitempty"`
+}
+
+// WebSocketEvent is an event pushed by the server.
+type WebSocketEvent struct {
+	Event     string              `json:"event"`
+	Data      map[string]any      `json:"data"`
+	Broadcast *WebsocketBroadcast `json:"broadcast"`
+	Sequence  int64               `json:"seq"`
+}
+
+// WebSocketRequest is an action sent to the server.
+type WebSocketRequest struct {
+	Seq    int64          `json:"seq"`
+	Action string         `json:"action"`
+	Data   map[string]any `json:"data"`
+}
+
+// WebSocketResponse is the server's reply to a WebSocketRequest.
+type WebSocketResponse struct {
+	Status   string         `json:"status"`
+	SeqReply int64          `json:"seq_reply,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+	Error    *AppError      `json:"error,omitempty"`
+}
+
+// websocketMessage is used to tell events and responses apart.
+type websocketMessage struct {
+	Event     string              `json:"event"`
+	Data      map[string]any      `json:"data"`
+	Broadcast *WebsocketBroadcast `json:"broadcast"`
+	Sequence  int64               `json:"seq"`
+	Status    string              `json:"status"`
+	SeqReply  int64               `json:"seq_reply"`
+	Error     *AppError           `json:"error"`
+}
+
+// WebSocketClient receives events from /api/v4/websocket with the credentials
+// of a Client4. Run keeps the connection open, reconnecting with exponential
+// backoff and resuming the previous connection when the server still has the
+// events that were missed. A WebSocketClient runs once; create a new one to
+// connect again after Run returns.
+type WebSocketClient struct {
+	URL    string            // The websocket location, for example "ws://localhost:8065/api/v4/websocket"
+	Dialer *websocket.Dialer // The dialer used to connect, built from the Client4's transport by default
+
+	// Zero durations use the matching DefaultWebSocket* value.
+	PingInterval      time.Duration // How often the connection is checked with a ping
+	PongTimeout       time.Duration // How long to wait for the answer to a ping
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+
+	EventChannel    chan *WebSocketEvent    // Events, closed when Run returns
+	ResponseChannel chan *WebSocketResponse // Replies to sent actions, dropped when full
+
+	client *Client4
+	seq    atomic.Int64
+	ran    atomic.Bool
+
+	mu           sync.Mutex // Guards conn and writes to it
+	conn         *websocket.Conn
+	connectionId string
+	nextSequence int64
+}
+
+// NewWebSocketClient returns a websocket client authenticated like c. The
+// token is read again on every reconnect, so a token changed with SetToken or
+// renewed by Login is picked up. The connection is dialed with the dial
+// functions, proxy and TLS settings of c.HTTPClient's *http.Transport, so it
+// also works for clients returned by NewLocalClient. Middlewares registered
+// with Use only apply to REST requests.
+func NewWebSocketClient(c *Client4) *WebSocketClient {
+	wsURL := c.APIURL + "/websocket"
+	if strings.HasPrefix(wsURL, "https://") {
+		wsURL = "wss://" + strings.TrimPrefix(wsURL, "https://")
+	} else {
+		wsURL = "ws://" + strings.TrimPrefix(wsURL, "http://")
+	}
+
+	return &WebSocketClient{
+		URL:               wsURL,
+		Dialer:            newWebSocketDialer(c.HTTPClient),
+		PingInterval:      DefaultWebSocketPingInterval,
+		PongTimeout:       DefaultWebSocketPongTimeout,
+		MinReconnectDelay: DefaultWebSocketMinReconnectDelay,
+		MaxReconnectDelay: DefaultWebSocketMaxReconnectDelay,
+		EventChannel:      make(chan *WebSocketEvent, websocketChannelSize),
+		ResponseChannel:   make(chan *WebSocketResponse, websocketChannelSize),
+		client:            c,
+	}
+}
+
+// newWebSocketDialer returns a dialer connecting like hc. Transports other than
+// *http.Transport cannot be reused and get the default dialer settings.
+func newWebSocketDialer(hc *http.Client) *websocket.Dialer {
+	d := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocketHandshakeTimeout,
+	}
+	if hc == nil {
+		return d
+	}
+	d.Jar = hc.Jar
+
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if tr, ok := rt.(*http.Transport); ok {
+		d.Proxy = tr.Proxy
+		d.NetDialContext = tr.DialContext
+		d.NetDialTLSContext = tr.DialTLSContext
+		if tr.TLSClientConfig != nil {
+			d.TLSClientConfig = tr.TLSClientConfig.Clone()
+		}
+	}
+	return d
+}
+
+// Run connects and delivers events on EventChannel until ctx is done or the
+// server rejects the credentials. Transient failures are retried. Run closes
+// EventChannel when it returns and fails when called more than once.
+func (ws *WebSocketClient) Run(ctx context.Context) error {
+	if ws.ran.Swap(true) {
+		return errors.New("mattermost: websocket client already ran")
+	}
+	defer close(ws.EventChannel)
+
+	minDelay := durationOr(ws.MinReconnectDelay, DefaultWebSocketMinReconnectDelay)
+	maxDelay := max(durationOr(ws.MaxReconnectDelay, DefaultWebSocketMaxReconnectDelay), minDelay)
+	delay := minDelay
+	for {
+		connected, err := ws.connectAndListen(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var appErr *AppError
+		if errors.As(err, &appErr) && (appErr.StatusCode == http.StatusUnauthorized || appErr.StatusCode == http.StatusForbidden) {
+			return err
+		}
+
+		if connected {
+			delay = minDelay
+		}
+		wait := delay/2 + rand.N(delay/2+1)
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// durationOr returns d, or def when d is not positive.
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// SendMessage sends an action to the server and returns its sequence number,
+// which the reply on ResponseChannel carries as SeqReply.
+func (ws *WebSocketClient) SendMessage(action string, data map[string]any) (int64, error) {
+	req := &WebSocketRequest{
+		Seq:    ws.seq.Add(1),
+		Action: action,
+		Data:   data,
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		return 0, errors.New("mattermost: websocket is not connected")
+	}
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	return req.Seq, ws.conn.WriteJSON(req)
+}
+
+// ConnectionId returns the id the server assigned to the current connection.
+func (ws *WebSocketClient) ConnectionId() string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.connectionId
+}
+
+// connectAndListen runs a single connection. It reports whether the server
+// accepted it, so that the reconnect delay can be reset.
+func (ws *WebSocketClient) connectAndListen(ctx context.Context) (bool, error) {
+	ws.mu.Lock()
+	connectionId, sequence := ws.connectionId, ws.nextSequence
+	ws.mu.Unlock()
+
+	u, err := url.Parse(ws.URL)
+	if err != nil {
+		return false, err
+	}
+	if connectionId != "" {
+		q := u.Query()
+		q.Set("connection_id", connectionId)
+		q.Set("sequence_number", strconv.FormatInt(sequence, 10))
+		u.RawQuery = q.Encode()
+	}
+
+	header := http.Header{}
+	auth := ws.client.authorization()
+	if auth != "" {
+		header.Set(HeaderAuth, auth)
+	}
+	for k, v := range ws.client.HTTPHeader {
+		header.Set(k, v)
+	}
+
+	conn, rp, err := ws.Dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if rp != nil && rp.StatusCode >= 300 {
+			defer closeBody(rp)
+			return false, AppErrorFromResponse(rp)
+		}
+		return false, err
+	}
+	defer conn.Close()
+
+	ws.mu.Lock()
+	ws.conn = conn
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		ws.conn = nil
+		ws.mu.Unlock()
+	}()
+
+	if auth != "" {
+		token := strings.TrimSpace(auth[strings.IndexByte(auth, ' ')+1:])
+		if _, err := ws.SendMessage(WebsocketAuthenticationChallenge, map[string]any{"token": token}); err != nil {
+			return false, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go ws.pingLoop(ctx, conn)
+
+	readTimeout := durationOr(ws.PingInterval, DefaultWebSocketPingInterval) + durationOr(ws.PongTimeout, DefaultWebSocketPongTimeout)
+	_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+
+	connected := false
+	for {
+		var msg websocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return connected, err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+		if msg.Event == "" {
+			ws.deliverResponse(&WebSocketResponse{Status: msg.Status, SeqReply: msg.SeqReply, Data: msg.Data, Error: msg.Error})
+			continue
+		}
+
+		event := WebSocketEvent{Event: msg.Event, Data: msg.Data, Broadcast: msg.Broadcast, Sequence: msg.Sequence}
+		if event.Event == WebsocketEventHello {
+			connected = true
+		}
+		if missed := ws.track(&event, connectionId); missed != nil {
+			if err := ws.deliverEvent(ctx, missed); err != nil {
+				return connected, err
+			}
+		}
+		if err := ws.deliverEvent(ctx, &event); err != nil {
+			return connected, err
+		}
+	}
+}
+
+// track updates the connection id and expected sequence number from event and
+// returns a WebsocketEventMissedEvents event when events were lost.
+func (ws *WebSocketClient) track(event *WebSocketEvent, previousId string) *WebSocketEvent {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var missed *WebSocketEvent
+	if event.Event == WebsocketEventHello {
+		id, _ := event.Data["connection_id"].(string)
+		if previousId != "" && id != previousId {
+			// The server could not resume the connection.
+			missed = &WebSocketEvent{
+				Event: WebsocketEventMissedEvents,
+				Data:  map[string]any{"reason": "reconnect", "previous_connection_id": previousId},
+			}
+		}
+		if id != previousId {
+			ws.nextSequence = event.Sequence
+		}
+		ws.connectionId = id
+	} else if event.Sequence != ws.nextSequence {
+		missed = &WebSocketEvent{
+			Event: WebsocketEventMissedEvents,
+			Data:  map[string]any{"reason": "sequence", "expected": ws.nextSequence, "received": event.Sequence},
+		}
+	}
+	if event.Sequence >= ws.nextSequence {
+		ws.nextSequence = event.Sequence + 1
+	}
+	return missed
+}
+
+func (ws *WebSocketClient) deliverEvent(ctx context.Context, event *WebSocketEvent) error {
+	select {
+	case ws.EventChannel <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ws *WebSocketClient) deliverResponse(rp *WebSocketResponse) {
+	select {
+	case ws.ResponseChannel <- rp:
+	default:
+	}
+}
+
+func (ws *WebSocketClient) pingLoop(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(durationOr(ws.PingInterval, DefaultWebSocketPingInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ws.mu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteTimeout))
+			ws.mu.Unlock()
+			if err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
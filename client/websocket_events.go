@@ -0,0 +1,368 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	WebsocketEventTyping               = "typing"
+	WebsocketEventPosted               = "posted"
+	WebsocketEventPostEdited           = "post_edited"
+	WebsocketEventPostDeleted          = "post_deleted"
+	WebsocketEventReactionAdded        = "reaction_added"
+	WebsocketEventReactionRemoved      = "reaction_removed"
+	WebsocketEventUserAdded            = "user_added"
+	WebsocketEventUserRemoved          = "user_removed"
+	WebsocketEventChannelCreated       = "channel_created"
+	WebsocketEventChannelUpdated       = "channel_updated"
+	WebsocketEventChannelDeleted       = "channel_deleted"
+	WebsocketEventChannelViewed        = "channel_viewed"
+	WebsocketEventChannelMemberUpdated = "channel_member_updated"
+	WebsocketEventStatusChange         = "status_change"
+	WebsocketEventThreadUpdated        = "thread_updated"
+)
+
+type HelloEvent struct {
+	ConnectionId  string
+	ServerVersion string
+}
+
+type PostedEvent struct {
+	Post               *Post
+	ChannelDisplayName string
+	ChannelName        string
+	ChannelType        ChannelType
+	SenderName         string
+	TeamId             string
+	Mentions           []string // Ids of the users mentioned in the post
+	SetOnline          bool
+}
+
+type PostEditedEvent struct {
+	Post *Post
+}
+
+type PostDeletedEvent struct {
+	Post     *Post
+	DeleteBy string // Id of the user who deleted the post, when not its author
+}
+
+type ReactionAddedEvent struct {
+	Reaction *Reaction
+}
+
+type ReactionRemovedEvent struct {
+	Reaction *Reaction
+}
+
+type TypingEvent struct {
+	UserId    string
+	ChannelId string
+	ParentId  string // Root post id when typing in a thread
+}
+
+type UserAddedEvent struct {
+	UserId    string
+	TeamId    string
+	ChannelId string
+}
+
+type UserRemovedEvent struct {
+	UserId    string
+	RemoverId string
+	ChannelId string
+}
+
+type ChannelCreatedEvent struct {
+	ChannelId string
+	TeamId    string
+}
+
+type ChannelUpdatedEvent struct {
+	Channel *Channel
+}
+
+type ChannelDeletedEvent struct {
+	ChannelId string
+	DeleteAt  int64
+}
+
+type ChannelViewedEvent struct {
+	ChannelId string
+}
+
+type ChannelMemberUpdatedEvent struct {
+	Member *ChannelMember
+}
+
+type StatusChangeEvent struct {
+	UserId string
+	Status string
+}
+
+type ThreadUpdatedEvent struct {
+	Thread                 *ThreadResponse
+	PreviousUnreadReplies  int64
+	PreviousUnreadMentions int64
+}
+
+// EventDecoder turns a raw websocket event into a typed value.
+type EventDecoder func(ev *WebSocketEvent) (any, error)
+
+// EventRegistry maps event names to decoders. It is safe for concurrent use.
+type EventRegistry struct {
+	mu       sync.RWMutex
+	decoders map[string]EventDecoder
+}
+
+// DefaultEventRegistry decodes the events of the Mattermost server. Plugins
+// events, named "custom_<plugin id>_<event>", can be added with Register.
+var DefaultEventRegistry = NewEventRegistry()
+
+// NewEventRegistry returns a registry knowing the server events.
+func NewEventRegistry() *EventRegistry {
+	r := &EventRegistry{decoders: map[string]EventDecoder{}}
+	r.Register(WebsocketEventHello, decodeHello)
+	r.Register(WebsocketEventPosted, decodePosted)
+	r.Register(WebsocketEventPostEdited, decodePostEdited)
+	r.Register(WebsocketEventPostDeleted, decodePostDeleted)
+	r.Register(WebsocketEventReactionAdded, decodeReactionAdded)
+	r.Register(WebsocketEventReactionRemoved, decodeReactionRemoved)
+	r.Register(WebsocketEventTyping, decodeTyping)
+	r.Register(WebsocketEventUserAdded, decodeUserAdded)
+	r.Register(WebsocketEventUserRemoved, decodeUserRemoved)
+	r.Register(WebsocketEventChannelCreated, decodeChannelCreated)
+	r.Register(WebsocketEventChannelUpdated, decodeChannelUpdated)
+	r.Register(WebsocketEventChannelDeleted, decodeChannelDeleted)
+	r.Register(WebsocketEventChannelViewed, decodeChannelViewed)
+	r.Register(WebsocketEventChannelMemberUpdated, decodeChannelMemberUpdated)
+	r.Register(WebsocketEventStatusChange, decodeStatusChange)
+	r.Register(WebsocketEventThreadUpdated, decodeThreadUpdated)
+	return r
+}
+
+// Register sets the decoder for an event name, replacing any previous one.
+func (r *EventRegistry) Register(event string, decoder EventDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[event] = decoder
+}
+
+// Decode returns the typed value for ev. Events without a decoder are
+// returned unchanged as *WebSocketEvent.
+func (r *EventRegistry) Decode(ev *WebSocketEvent) (any, error) {
+	r.mu.RLock()
+	decoder, ok := r.decoders[ev.Event]
+	r.mu.RUnlock()
+	if !ok {
+		return ev, nil
+	}
+	v, err := decoder(ev)
+	if err != nil {
+		return nil, fmt.Errorf("mattermost: decoding %s event: %w", ev.Event, err)
+	}
+	return v, nil
+}
+
+// DecodeEvent decodes ev with DefaultEventRegistry.
+func DecodeEvent(ev *WebSocketEvent) (any, error) {
+	return DefaultEventRegistry.Decode(ev)
+}
+
+// RegisterEventType registers a decoder unmarshalling the data of an event,
+// typically a plugin event, into a new T.
+func RegisterEventType[T any](r *EventRegistry, event string) {
+	r.Register(event, func(ev *WebSocketEvent) (any, error) {
+		b, err := json.Marshal(ev.Data)
+		if err != nil {
+			return nil, err
+		}
+		v := new(T)
+		if err := json.Unmarshal(b, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+}
+
+func (ev *WebSocketEvent) stringData(key string) string {
+	s, _ := ev.Data[key].(string)
+	return s
+}
+
+func (ev *WebSocketEvent) int64Data(key string) int64 {
+	switch n := ev.Data[key].(type) {
+	case float64:
+		return int64(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	}
+	return 0
+}
+
+// jsonData decodes a payload the server sends JSON encoded inside a string
+// field, such as the post of a posted event.
+func (ev *WebSocketEvent) jsonData(key string, v any) error {
+	s, ok := ev.Data[key].(string)
+	if !ok {
+		return fmt.Errorf("missing %q", key)
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+func (ev *WebSocketEvent) broadcastChannelId() string {
+	if ev.Broadcast == nil {
+		return ""
+	}
+	return ev.Broadcast.ChannelId
+}
+
+func decodeHello(ev *WebSocketEvent) (any, error) {
+	return &HelloEvent{
+		ConnectionId:  ev.stringData("connection_id"),
+		ServerVersion: ev.stringData("server_version"),
+	}, nil
+}
+
+func decodePosted(ev *WebSocketEvent) (any, error) {
+	var post Post
+	if err := ev.jsonData("post", &post); err != nil {
+		return nil, err
+	}
+	e := &PostedEvent{
+		Post:               &post,
+		ChannelDisplayName: ev.stringData("channel_display_name"),
+		ChannelName:        ev.stringData("channel_name"),
+		ChannelType:        ChannelType(ev.stringData("channel_type")),
+		SenderName:         ev.stringData("sender_name"),
+		TeamId:             ev.stringData("team_id"),
+	}
+	if _, ok := ev.Data["mentions"]; ok {
+		if err := ev.jsonData("mentions", &e.Mentions); err != nil {
+			return nil, err
+		}
+	}
+	e.SetOnline, _ = ev.Data["set_online"].(bool)
+	return e, nil
+}
+
+func decodePostEdited(ev *WebSocketEvent) (any, error) {
+	var post Post
+	if err := ev.jsonData("post", &post); err != nil {
+		return nil, err
+	}
+	return &PostEditedEvent{Post: &post}, nil
+}
+
+func decodePostDeleted(ev *WebSocketEvent) (any, error) {
+	var post Post
+	if err := ev.jsonData("post", &post); err != nil {
+		return nil, err
+	}
+	return &PostDeletedEvent{Post: &post, DeleteBy: ev.stringData("delete_by")}, nil
+}
+
+func decodeReactionAdded(ev *WebSocketEvent) (any, error) {
+	var reaction Reaction
+	if err := ev.jsonData("reaction", &reaction); err != nil {
+		return nil, err
+	}
+	return &ReactionAddedEvent{Reaction: &reaction}, nil
+}
+
+func decodeReactionRemoved(ev *WebSocketEvent) (any, error) {
+	var reaction Reaction
+	if err := ev.jsonData("reaction", &reaction); err != nil {
+		return nil, err
+	}
+	return &ReactionRemovedEvent{Reaction: &reaction}, nil
+}
+
+func decodeTyping(ev *WebSocketEvent) (any, error) {
+	return &TypingEvent{
+		UserId:    ev.stringData("user_id"),
+		ChannelId: ev.broadcastChannelId(),
+		ParentId:  ev.stringData("parent_id"),
+	}, nil
+}
+
+func decodeUserAdded(ev *WebSocketEvent) (any, error) {
+	return &UserAddedEvent{
+		UserId:    ev.stringData("user_id"),
+		TeamId:    ev.stringData("team_id"),
+		ChannelId: ev.broadcastChannelId(),
+	}, nil
+}
+
+func decodeUserRemoved(ev *WebSocketEvent) (any, error) {
+	e := &UserRemovedEvent{
+		UserId:    ev.stringData("user_id"),
+		RemoverId: ev.stringData("remover_id"),
+		ChannelId: ev.stringData("channel_id"),
+	}
+	// Sent to the channel, the event names the removed user; sent to the
+	// removed user, it names the channel.
+	if e.ChannelId == "" {
+		e.ChannelId = ev.broadcastChannelId()
+	}
+	if e.UserId == "" && ev.Broadcast != nil {
+		e.UserId = ev.Broadcast.UserId
+	}
+	return e, nil
+}
+
+func decodeChannelCreated(ev *WebSocketEvent) (any, error) {
+	return &ChannelCreatedEvent{
+		ChannelId: ev.stringData("channel_id"),
+		TeamId:    ev.stringData("team_id"),
+	}, nil
+}
+
+func decodeChannelUpdated(ev *WebSocketEvent) (any, error) {
+	var channel Channel
+	if err := ev.jsonData("channel", &channel); err != nil {
+		return nil, err
+	}
+	return &ChannelUpdatedEvent{Channel: &channel}, nil
+}
+
+func decodeChannelDeleted(ev *WebSocketEvent) (any, error) {
+	return &ChannelDeletedEvent{
+		ChannelId: ev.stringData("channel_id"),
+		DeleteAt:  ev.int64Data("delete_at"),
+	}, nil
+}
+
+func decodeChannelViewed(ev *WebSocketEvent) (any, error) {
+	return &ChannelViewedEvent{ChannelId: ev.stringData("channel_id")}, nil
+}
+
+func decodeChannelMemberUpdated(ev *WebSocketEvent) (any, error) {
+	var member ChannelMember
+	if err := ev.jsonData("channelMember", &member); err != nil {
+		return nil, err
+	}
+	return &ChannelMemberUpdatedEvent{Member: &member}, nil
+}
+
+func decodeStatusChange(ev *WebSocketEvent) (any, error) {
+	return &StatusChangeEvent{
+		UserId: ev.stringData("user_id"),
+		Status: ev.stringData("status"),
+	}, nil
+}
+
+func decodeThreadUpdated(ev *WebSocketEvent) (any, error) {
+	var thread ThreadResponse
+	if err := ev.jsonData("thread", &thread); err != nil {
+		return nil, err
+	}
+	return &ThreadUpdatedEvent{
+		Thread:                 &thread,
+		PreviousUnreadReplies:  ev.int64Data("previous_unread_replies"),
+		PreviousUnreadMentions: ev.int64Data("previous_unread_mentions"),
+	}, nil
+}
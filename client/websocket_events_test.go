@@ -0,0 +1,118 @@
+package mattermost_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+		want any
+	}{
+		{
+			name: "hello",
+			raw:  `{"event":"hello","data":{"connection_id":"c1","server_version":"9.11"}}`,
+			want: &mattermost.HelloEvent{ConnectionId: "c1", ServerVersion: "9.11"},
+		},
+		{
+			name: "posted",
+			raw:  `{"event":"posted","data":{"post":"{\"id\":\"p1\",\"channel_id\":\"ch\",\"message\":\"hi\"}","channel_type":"D","sender_name":"@alice","mentions":"[\"u2\"]","set_online":true}}`,
+			want: &mattermost.PostedEvent{
+				Post:        &mattermost.Post{Id: "p1", ChannelId: "ch", Message: "hi"},
+				ChannelType: mattermost.ChannelTypeDirect,
+				SenderName:  "@alice",
+				Mentions:    []string{"u2"},
+				SetOnline:   true,
+			},
+		},
+		{
+			name: "post deleted",
+			raw:  `{"event":"post_deleted","data":{"post":"{\"id\":\"p1\"}","delete_by":"admin"}}`,
+			want: &mattermost.PostDeletedEvent{Post: &mattermost.Post{Id: "p1"}, DeleteBy: "admin"},
+		},
+		{
+			name: "reaction added",
+			raw:  `{"event":"reaction_added","data":{"reaction":"{\"user_id\":\"u1\",\"post_id\":\"p1\",\"emoji_name\":\"+1\"}"}}`,
+			want: &mattermost.ReactionAddedEvent{Reaction: &mattermost.Reaction{UserId: "u1", PostId: "p1", EmojiName: "+1"}},
+		},
+		{
+			name: "typing in a thread",
+			raw:  `{"event":"typing","data":{"user_id":"u1","parent_id":"root"},"broadcast":{"channel_id":"ch"}}`,
+			want: &mattermost.TypingEvent{UserId: "u1", ChannelId: "ch", ParentId: "root"},
+		},
+		{
+			name: "user removed, sent to the channel",
+			raw:  `{"event":"user_removed","data":{"user_id":"u1","remover_id":"u2"},"broadcast":{"channel_id":"ch"}}`,
+			want: &mattermost.UserRemovedEvent{UserId: "u1", RemoverId: "u2", ChannelId: "ch"},
+		},
+		{
+			name: "user removed, sent to the removed user",
+			raw:  `{"event":"user_removed","data":{"channel_id":"ch","remover_id":"u2"},"broadcast":{"user_id":"u1"}}`,
+			want: &mattermost.UserRemovedEvent{UserId: "u1", RemoverId: "u2", ChannelId: "ch"},
+		},
+		{
+			name: "channel deleted",
+			raw:  `{"event":"channel_deleted","data":{"channel_id":"ch","delete_at":1700000000000}}`,
+			want: &mattermost.ChannelDeletedEvent{ChannelId: "ch", DeleteAt: 1700000000000},
+		},
+		{
+			name: "status change",
+			raw:  `{"event":"status_change","data":{"user_id":"u1","status":"away"}}`,
+			want: &mattermost.StatusChangeEvent{UserId: "u1", Status: "away"},
+		},
+		{
+			name: "unknown event",
+			raw:  `{"event":"custom_com.example_ping","data":{"n":1}}`,
+			want: &mattermost.WebSocketEvent{Event: "custom_com.example_ping", Data: map[string]any{"n": float64(1)}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ev mattermost.WebSocketEvent
+			if err := json.Unmarshal([]byte(tc.raw), &ev); err != nil {
+				t.Fatal(err)
+			}
+			got, err := mattermost.DecodeEvent(&ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("decoded %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEventError(t *testing.T) {
+	ev := &mattermost.WebSocketEvent{Event: mattermost.WebsocketEventPosted, Data: map[string]any{}}
+	if _, err := mattermost.DecodeEvent(ev); err == nil || !strings.Contains(err.Error(), "posted") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRegisterEventType(t *testing.T) {
+	type pingEvent struct {
+		Count int    `json:"count"`
+		From  string `json:"from"`
+	}
+	r := mattermost.NewEventRegistry()
+	mattermost.RegisterEventType[pingEvent](r, "custom_com.example_ping")
+
+	got, err := r.Decode(&mattermost.WebSocketEvent{Event: "custom_com.example_ping", Data: map[string]any{"count": 2, "from": "u1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping, ok := got.(*pingEvent); !ok || *ping != (pingEvent{Count: 2, From: "u1"}) {
+		t.Fatalf("decoded %#v", got)
+	}
+
+	// Registries are independent.
+	if got, _ := mattermost.DecodeEvent(&mattermost.WebSocketEvent{Event: "custom_com.example_ping"}); reflect.TypeOf(got) != reflect.TypeOf(&mattermost.WebSocketEvent{}) {
+		t.Fatalf("default registry decoded %#v", got)
+	}
+}
@@ -0,0 +1,265 @@
+package mattermost_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	mattermost "github.com/saygik/mattermost/client"
+)
+
+var upgrader = websocket.Upgrader{}
+
+// wsEvent returns an event as sent by the server.
+func wsEvent(event string, seq int64, data map[string]any) map[string]any {
+	return map[string]any{"event": event, "seq": seq, "data": data}
+}
+
+func hello(connectionId string, seq int64) map[string]any {
+	return wsEvent(mattermost.WebsocketEventHello, seq, map[string]any{"connection_id": connectionId})
+}
+
+// wsHandler upgrades every connection, checks the authentication challenge
+// and calls script with the number of the connection, starting at 1.
+func wsHandler(t *testing.T, script func(n int, conn *websocket.Conn, rq *http.Request)) http.Handler {
+	var count atomic.Int32
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		conn, err := upgrader.Upgrade(w, rq, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		var challenge mattermost.WebSocketRequest
+		if err := conn.ReadJSON(&challenge); err != nil {
+			return
+		}
+		if challenge.Action != mattermost.WebsocketAuthenticationChallenge || challenge.Data["token"] != "token" {
+			t.Errorf("challenge %+v", challenge)
+		}
+		script(int(count.Add(1)), conn, rq)
+		// Keep the connection open until the client goes away.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func newWebSocketClient(url string) *mattermost.WebSocketClient {
+	c := mattermost.NewAPIv4Client(url)
+	c.SetToken("token")
+	ws := mattermost.NewWebSocketClient(c)
+	ws.MinReconnectDelay = 10 * time.Millisecond
+	ws.MaxReconnectDelay = 20 * time.Millisecond
+	return ws
+}
+
+// collect runs ws until n events have been received.
+func collect(t *testing.T, ws *mattermost.WebSocketClient, n int) []*mattermost.WebSocketEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- ws.Run(ctx) }()
+
+	var events []*mattermost.WebSocketEvent
+	for len(events) < n {
+		select {
+		case ev, ok := <-ws.EventChannel:
+			if !ok {
+				t.Fatalf("run ended after %d events: %v", len(events), <-done)
+			}
+			events = append(events, ev)
+		case <-ctx.Done():
+			t.Fatalf("got %d events, want %d", len(events), n)
+		}
+	}
+	cancel()
+	<-done
+	return events
+}
+
+// describe summarizes events as "event:seq" or "client_missed_events:reason".
+func describe(events []*mattermost.WebSocketEvent) string {
+	var s []string
+	for _, ev := range events {
+		if ev.Event == mattermost.WebsocketEventMissedEvents {
+			s = append(s, fmt.Sprintf("%s:%v", ev.Event, ev.Data["reason"]))
+		} else {
+			s = append(s, fmt.Sprintf("%s:%d", ev.Event, ev.Sequence))
+		}
+	}
+	return fmt.Sprint(s)
+}
+
+func TestWebSocketSequence(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sent []map[string]any
+		want string
+	}{
+		{
+			name: "in order",
+			sent: []map[string]any{hello("c1", 0), wsEvent("posted", 1, nil), wsEvent("typing", 2, nil)},
+			want: "[hello:0 posted:1 typing:2]",
+		},
+		{
+			name: "gap",
+			sent: []map[string]any{hello("c1", 0), wsEvent("posted", 1, nil), wsEvent("posted", 3, nil)},
+			want: "[hello:0 posted:1 client_missed_events:sequence posted:3]",
+		},
+		{
+			name: "responses are not events",
+			sent: []map[string]any{hello("c1", 0), {"status": "OK", "seq_reply": 1}, wsEvent("posted", 1, nil)},
+			want: "[hello:0 posted:1]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(wsHandler(t, func(_ int, conn *websocket.Conn, _ *http.Request) {
+				for _, msg := range tc.sent {
+					_ = conn.WriteJSON(msg)
+				}
+			}))
+			defer srv.Close()
+
+			events := collect(t, newWebSocketClient(srv.URL), countEvents(tc.want))
+			if got := describe(events); got != tc.want {
+				t.Fatalf("events %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// countEvents returns the number of events in a describe string.
+func countEvents(s string) int {
+	n := 1
+	for _, r := range s {
+		if r == ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		hello map[string]any
+		next  map[string]any
+		want  string
+	}{
+		{
+			name:  "resumed",
+			hello: hello("c1", 0),
+			next:  wsEvent("posted", 2, nil),
+			want:  "[hello:0 posted:1 hello:0 posted:2]",
+		},
+		{
+			name:  "not resumed",
+			hello: hello("c2", 0),
+			next:  wsEvent("posted", 1, nil),
+			want:  "[hello:0 posted:1 client_missed_events:reconnect hello:0 posted:1]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(wsHandler(t, func(n int, conn *websocket.Conn, rq *http.Request) {
+				if n == 1 {
+					_ = conn.WriteJSON(hello("c1", 0))
+					_ = conn.WriteJSON(wsEvent("posted", 1, nil))
+					conn.Close()
+					return
+				}
+				q := rq.URL.Query()
+				if q.Get("connection_id") != "c1" || q.Get("sequence_number") != "2" {
+					t.Errorf("reconnected with %q", rq.URL.RawQuery)
+				}
+				_ = conn.WriteJSON(tc.hello)
+				_ = conn.WriteJSON(tc.next)
+			}))
+			defer srv.Close()
+
+			events := collect(t, newWebSocketClient(srv.URL), countEvents(tc.want))
+			if got := describe(events); got != tc.want {
+				t.Fatalf("events %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketRun(t *testing.T) {
+	t.Run("rejected credentials", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"id":"api.context.session_expired.app_error","status_code":401}`))
+		}))
+		defer srv.Close()
+
+		ws := newWebSocketClient(srv.URL)
+		if err := ws.Run(context.Background()); !errors.Is(err, mattermost.ErrUnauthorized) {
+			t.Fatalf("err = %v", err)
+		}
+		if _, ok := <-ws.EventChannel; ok {
+			t.Fatal("event channel not closed")
+		}
+		if err := ws.Run(context.Background()); err == nil {
+			t.Fatal("second run succeeded")
+		}
+	})
+
+	t.Run("zero reconnect delay", func(t *testing.T) {
+		var attempts atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		ws := newWebSocketClient(srv.URL)
+		ws.MinReconnectDelay, ws.MaxReconnectDelay = 0, 0
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		if err := ws.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v", err)
+		}
+		if n := attempts.Load(); n > 2 {
+			t.Fatalf("%d attempts in 200ms", n)
+		}
+	})
+}
+
+func TestWebSocketLocalClient(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mattermost.socket")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		conn, err := upgrader.Upgrade(w, rq, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteJSON(hello("local", 0))
+		_, _, _ = conn.ReadMessage()
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	ws := mattermost.NewWebSocketClient(mattermost.NewLocalClient(socket))
+	events := collect(t, ws, 1)
+	if events[0].Event != mattermost.WebsocketEventHello || events[0].Data["connection_id"] != "local" {
+		t.Fatalf("event %+v", events[0])
+	}
+}
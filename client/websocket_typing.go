@@ -0,0 +1,116 @@
+package mattermost
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	WebsocketUserTypingAction = "user_typing"
+
+	// TypingRefreshInterval is how often KeepTyping repeats the typing
+	// indicator. Clients hide it a few seconds after the last event.
+	TypingRefreshInterval = 3 * time.Second
+
+	// TypingTimeout is how long TypingTracker considers a user typing after
+	// their last typing event.
+	TypingTimeout = 5 * time.Second
+)
+
+// UserTyping shows the typing indicator of the client's user in a channel, or
+// in a thread when parentId is the id of its root post.
+func (ws *WebSocketClient) UserTyping(channelId, parentId string) error {
+	data := map[string]any{
+		"channel_id": channelId,
+		"parent_id":  parentId,
+	}
+	_, err := ws.SendMessage(WebsocketUserTypingAction, data)
+	return err
+}
+
+// KeepTyping shows the typing indicator until the returned stop function is
+// called or ctx is done. Errors are ignored as the indicator is cosmetic and
+// the connection may be reconnecting.
+func (ws *WebSocketClient) KeepTyping(ctx context.Context, channelId, parentId string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(TypingRefreshInterval)
+		defer ticker.Stop()
+		for {
+			_ = ws.UserTyping(channelId, parentId)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// TypingTracker keeps track of who is typing from typing and posted events.
+// It is safe for concurrent use.
+type TypingTracker struct {
+	mu     sync.Mutex
+	typing map[typingKey]time.Time
+	pruned time.Time
+}
+
+type typingKey struct {
+	channelId string
+	parentId  string
+	userId    string
+}
+
+func NewTypingTracker() *TypingTracker {
+	return &TypingTracker{typing: map[typingKey]time.Time{}, pruned: time.Now()}
+}
+
+// Observe updates the tracker from a decoded websocket event. A typing event
+// marks the user as typing; a post by the user ends it. Other events are ignored.
+// Users who stopped typing without posting are forgotten here too, so the
+// tracker does not grow when Typing is never called.
+func (t *TypingTracker) Observe(event any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.pruned) > TypingTimeout {
+		for k, at := range t.typing {
+			if now.Sub(at) > TypingTimeout {
+				delete(t.typing, k)
+			}
+		}
+		t.pruned = now
+	}
+	switch e := event.(type) {
+	case *TypingEvent:
+		t.typing[typingKey{e.ChannelId, e.ParentId, e.UserId}] = now
+	case *PostedEvent:
+		delete(t.typing, typingKey{e.Post.ChannelId, e.Post.RootId, e.Post.UserId})
+	}
+}
+
+// Typing returns the ids of the users typing in a channel, or in a thread
+// when parentId is set.
+func (t *TypingTracker) Typing(channelId, parentId string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	var users []string
+	for k, at := range t.typing {
+		if now.Sub(at) > TypingTimeout {
+			delete(t.typing, k)
+			continue
+		}
+		if k.channelId == channelId && k.parentId == parentId {
+			users = append(users, k.userId)
+		}
+	}
+	return users
+}
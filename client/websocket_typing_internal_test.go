@@ -0,0 +1,25 @@
+package mattermost
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypingTrackerObservePrunes(t *testing.T) {
+	tracker := NewTypingTracker()
+	stale := time.Now().Add(-2 * TypingTimeout)
+	tracker.typing[typingKey{"ch", "root", "u1"}] = stale
+	tracker.typing[typingKey{"other", "", "u2"}] = stale
+
+	// Pruning runs at most once per TypingTimeout.
+	tracker.Observe(&TypingEvent{UserId: "u3", ChannelId: "ch"})
+	if len(tracker.typing) != 3 {
+		t.Fatalf("typing %v, want the stale entries kept", tracker.typing)
+	}
+
+	tracker.pruned = stale
+	tracker.Observe(&TypingEvent{UserId: "u4", ChannelId: "ch"})
+	if len(tracker.typing) != 2 {
+		t.Fatalf("typing %v, want u3 and u4", tracker.typing)
+	}
+}
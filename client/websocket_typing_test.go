@@ -0,0 +1,116 @@
+package mattermost_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	mattermost "github.com/saygik/mattermost/client"
+	"github.com/saygik/mattermost/client/mattermosttest"
+)
+
+func TestTypingTracker(t *testing.T) {
+	typing := func(userId, channelId, parentId string) *mattermost.TypingEvent {
+		return &mattermost.TypingEvent{UserId: userId, ChannelId: channelId, ParentId: parentId}
+	}
+	posted := func(userId, channelId, rootId string) *mattermost.PostedEvent {
+		return &mattermost.PostedEvent{Post: &mattermost.Post{UserId: userId, ChannelId: channelId, RootId: rootId}}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		events   []any
+		channel  string
+		parentId string
+		want     []string
+	}{
+		{name: "nobody", channel: "ch"},
+		{name: "typing", events: []any{typing("u1", "ch", ""), typing("u2", "ch", "")}, channel: "ch", want: []string{"u1", "u2"}},
+		{name: "other channel", events: []any{typing("u1", "other", "")}, channel: "ch"},
+		{name: "thread is separate", events: []any{typing("u1", "ch", "root"), typing("u2", "ch", "")}, channel: "ch", parentId: "root", want: []string{"u1"}},
+		{name: "post ends typing", events: []any{typing("u1", "ch", ""), typing("u2", "ch", ""), posted("u1", "ch", "")}, channel: "ch", want: []string{"u2"}},
+		{name: "post elsewhere does not", events: []any{typing("u1", "ch", ""), posted("u1", "ch", "root")}, channel: "ch", want: []string{"u1"}},
+		{name: "other events are ignored", events: []any{&mattermost.StatusChangeEvent{UserId: "u1"}, &mattermost.WebSocketEvent{}}, channel: "ch"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := mattermost.NewTypingTracker()
+			for _, ev := range tc.events {
+				tracker.Observe(ev)
+			}
+			got := tracker.Typing(tc.channel, tc.parentId)
+			slices.Sort(got)
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("typing %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeepTyping(t *testing.T) {
+	s := mattermosttest.NewServer()
+	defer s.Close()
+	alice := s.AddUser(&mattermost.User{Username: "alice"}, "secret")
+	bob := s.AddUser(&mattermost.User{Username: "bob"}, "secret")
+	ch := s.AddChannel(&mattermost.Channel{Name: "town-square", Type: mattermost.ChannelTypeOpen})
+	s.AddChannelMember(ch.Id, alice.Id)
+	s.AddChannelMember(ch.Id, bob.Id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	connect := func(userId string) *mattermost.WebSocketClient {
+		ws := mattermost.NewWebSocketClient(s.Client(userId))
+		go ws.Run(ctx)
+		<-ws.EventChannel // hello
+		return ws
+	}
+	aliceWS, bobWS := connect(alice.Id), connect(bob.Id)
+
+	tracker := mattermost.NewTypingTracker()
+	stop := aliceWS.KeepTyping(ctx, ch.Id, "root")
+	select {
+	case ev := <-bobWS.EventChannel:
+		decoded, err := mattermost.DecodeEvent(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracker.Observe(decoded)
+	case <-ctx.Done():
+		t.Fatal("no typing event")
+	}
+	stop()
+
+	if got := tracker.Typing(ch.Id, "root"); !slices.Equal(got, []string{alice.Id}) {
+		t.Fatalf("typing %v", got)
+	}
+
+	// The reply to the typing action is delivered to the sender.
+	select {
+	case rp := <-aliceWS.ResponseChannel:
+		if rp.Status != mattermost.StatusOk {
+			t.Fatalf("response %+v", rp)
+		}
+	case <-ctx.Done():
+		t.Fatal("no response")
+	}
+
+	// Typing in a channel the user is not a member of fails.
+	seq, err := aliceWS.SendMessage(mattermost.WebsocketUserTypingAction, map[string]any{"channel_id": mattermosttest.NewId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case rp := <-aliceWS.ResponseChannel:
+			if rp.SeqReply != seq {
+				continue
+			}
+			if rp.Status != mattermost.StatusFail || rp.Error == nil {
+				t.Fatalf("response %+v", rp)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("no response")
+		}
+	}
+}
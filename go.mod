@@ -0,0 +1,5 @@
+module github.com/saygik/mattermost
+
+go 1.23
+
+require github.com/gorilla/websocket v1.5.3